@@ -6,6 +6,8 @@ type Aggregate struct {
 	fn    string
 	arg   string
 	alias string
+	// distinct 为 true 的时候生成 fn(DISTINCT arg)
+	distinct bool
 }
 
 func (a Aggregate) selectedAlias() string {
@@ -23,11 +25,8 @@ func (a Aggregate) target() TableReference {
 func (a Aggregate) expr() {}
 
 func (a Aggregate) As(alias string) Aggregate {
-	return Aggregate{
-		fn:    a.fn,
-		arg:   a.arg,
-		alias: alias,
-	}
+	a.alias = alias
+	return a
 }
 
 // EQ 例如 C("id").EQ(12)
@@ -55,6 +54,45 @@ func (a Aggregate) GT(arg any) Predicate {
 	}
 }
 
+func (a Aggregate) NEQ(arg any) Predicate {
+	return newPredicate(a, opNEQ, arg)
+}
+
+func (a Aggregate) LTEQ(arg any) Predicate {
+	return newPredicate(a, opLTEQ, arg)
+}
+
+func (a Aggregate) GTEQ(arg any) Predicate {
+	return newPredicate(a, opGTEQ, arg)
+}
+
+func (a Aggregate) In(vals ...any) Predicate {
+	return inPredicate(a, opIN, vals)
+}
+
+func (a Aggregate) NotIn(vals ...any) Predicate {
+	return inPredicate(a, opNotIN, vals)
+}
+
+func (a Aggregate) Like(pattern any) Predicate {
+	return newPredicate(a, opLike, pattern)
+}
+
+func (a Aggregate) NotLike(pattern any) Predicate {
+	return newPredicate(a, opNotLike, pattern)
+}
+
+func (a Aggregate) Between(lo, hi any) Predicate {
+	return betweenPredicate(a, lo, hi)
+}
+
+func (a Aggregate) IsNull() Predicate {
+	return nullPredicate(a, opIsNull)
+}
+
+func (a Aggregate) IsNotNull() Predicate {
+	return nullPredicate(a, opIsNotNull)
+}
 
 func Avg(c string) Aggregate {
 	return Aggregate{
@@ -89,4 +127,113 @@ func Sum(c string) Aggregate {
 		fn:  "SUM",
 		arg: c,
 	}
-}
\ No newline at end of file
+}
+
+// CountDistinct 例如 CountDistinct("Age") 生成 COUNT(DISTINCT `age`)
+func CountDistinct(c string) Aggregate {
+	return Aggregate{
+		fn:       "COUNT",
+		arg:      c,
+		distinct: true,
+	}
+}
+
+func SumDistinct(c string) Aggregate {
+	return Aggregate{
+		fn:       "SUM",
+		arg:      c,
+		distinct: true,
+	}
+}
+
+func AvgDistinct(c string) Aggregate {
+	return Aggregate{
+		fn:       "AVG",
+		arg:      c,
+		distinct: true,
+	}
+}
+
+// aggregate 构造 table 上的聚合函数，例如 t1.Avg("Amount") 生成 AVG(`t1`.`amount`)
+func (t Table) aggregate(fn string, c string, distinct bool) Aggregate {
+	return Aggregate{
+		table:    t,
+		fn:       fn,
+		arg:      c,
+		distinct: distinct,
+	}
+}
+
+func (t Table) Avg(c string) Aggregate {
+	return t.aggregate("AVG", c, false)
+}
+
+func (t Table) Max(c string) Aggregate {
+	return t.aggregate("MAX", c, false)
+}
+
+func (t Table) Min(c string) Aggregate {
+	return t.aggregate("MIN", c, false)
+}
+
+func (t Table) Count(c string) Aggregate {
+	return t.aggregate("COUNT", c, false)
+}
+
+func (t Table) Sum(c string) Aggregate {
+	return t.aggregate("SUM", c, false)
+}
+
+func (t Table) CountDistinct(c string) Aggregate {
+	return t.aggregate("COUNT", c, true)
+}
+
+func (t Table) SumDistinct(c string) Aggregate {
+	return t.aggregate("SUM", c, true)
+}
+
+func (t Table) AvgDistinct(c string) Aggregate {
+	return t.aggregate("AVG", c, true)
+}
+
+// 子查询和 CTE 上的聚合函数，列按照它们对应的 Table 来校验
+
+func (s Subquery) Avg(c string) Aggregate {
+	return s.asTable().Avg(c)
+}
+
+func (s Subquery) Max(c string) Aggregate {
+	return s.asTable().Max(c)
+}
+
+func (s Subquery) Min(c string) Aggregate {
+	return s.asTable().Min(c)
+}
+
+func (s Subquery) Count(c string) Aggregate {
+	return s.asTable().Count(c)
+}
+
+func (s Subquery) Sum(c string) Aggregate {
+	return s.asTable().Sum(c)
+}
+
+func (c CTE) Avg(col string) Aggregate {
+	return c.asTable().Avg(col)
+}
+
+func (c CTE) Max(col string) Aggregate {
+	return c.asTable().Max(col)
+}
+
+func (c CTE) Min(col string) Aggregate {
+	return c.asTable().Min(col)
+}
+
+func (c CTE) Count(col string) Aggregate {
+	return c.asTable().Count(col)
+}
+
+func (c CTE) Sum(col string) Aggregate {
+	return c.asTable().Sum(col)
+}
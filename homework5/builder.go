@@ -1,6 +1,8 @@
 package orm
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"exercise/geektime/homework5/version1/internal/errs"
 	"exercise/geektime/homework5/version1/model"
 	"strings"
@@ -13,6 +15,9 @@ type builder struct {
 	dialect Dialect
 	quoter  byte
 	model   *model.Model
+	// argOffset 是外层查询已经使用的参数数量
+	// 作为子查询构造的时候，占位符的编号要从这里接着往下
+	argOffset int
 }
 
 // buildColumn 构造列
@@ -53,6 +58,12 @@ func (b *builder) colName(table TableReference, fd string) (string, error) {
 		//if b.model != nil {
 		//	b.model, err = b.r.Get(tab.entity)
 		//}
+		// 子查询里面带别名的列，例如窗口函数，外面直接用别名来引用
+		for _, col := range tab.cols {
+			if alias := col.selectedAlias(); alias != "" && alias == fd {
+				return alias, nil
+			}
+		}
 		var err error
 		m, err := b.r.Get(tab.entity)
 
@@ -74,19 +85,126 @@ func (b *builder) colName(table TableReference, fd string) (string, error) {
 	}
 }
 
+// fieldOf 返回列对应的字段元数据，找不到的时候返回 nil
+func (b *builder) fieldOf(table TableReference, fd string) *model.Field {
+	m := b.model
+	if tab, ok := table.(Table); ok {
+		m, _ = b.r.Get(tab.entity)
+	} else if table != nil {
+		return nil
+	}
+	if m == nil {
+		return nil
+	}
+	return m.FieldMap[fd]
+}
+
+// jsonValue 如果 left 是 JSON 列，那么把 right 里面的值编码成 JSON，
+// 和通过实例写入的时候保持一致。实现了 driver.Valuer 的值自己负责编码
+func (b *builder) jsonValue(left Expression, right Expression) (Expression, error) {
+	col, ok := left.(Column)
+	if !ok {
+		return right, nil
+	}
+	if fd := b.fieldOf(col.table, col.name); fd == nil || !fd.JSON {
+		return right, nil
+	}
+	switch r := right.(type) {
+	case value:
+		val, err := jsonArg(r.val)
+		if err != nil {
+			return nil, err
+		}
+		return value{val: val}, nil
+	case valuesExpr:
+		vals := make([]any, 0, len(r.vals))
+		for _, v := range r.vals {
+			val, err := jsonArg(v)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+		return valuesExpr{vals: vals}, nil
+	default:
+		return right, nil
+	}
+}
+
+func jsonArg(arg any) (any, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return arg, nil
+	}
+	bs, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+	return string(bs), nil
+}
+
 func (b *builder) quote(name string) {
 	b.sb.WriteByte(b.quoter)
 	b.sb.WriteString(name)
 	b.sb.WriteByte(b.quoter)
 }
 
+// raw 构造原生表达式
+// RawExpr 里面统一使用 ? 作为占位符，这里会转换为方言的占位符
 func (b *builder) raw(r RawExpr) {
-	b.sb.WriteString(r.raw)
-	if len(r.args) != 0 {
-		b.addArgs(r.args...)
+	if len(r.args) == 0 {
+		b.sb.WriteString(r.raw)
+		return
+	}
+	idx := 0
+	for _, ch := range r.raw {
+		if ch == '?' && idx < len(r.args) {
+			b.parameter(r.args[idx])
+			idx++
+			continue
+		}
+		b.sb.WriteRune(ch)
+	}
+	// 占位符比参数少，多出来的参数原样传递，交给数据库报错
+	if idx < len(r.args) {
+		b.addArgs(r.args[idx:]...)
+	}
+}
+
+// parameter 写入一个占位符并且记录对应的参数
+// 占位符的形式由方言决定，例如 MySQL 是 ?，而 PostgreSQL 是 $1
+func (b *builder) parameter(arg any) {
+	b.addArgs(arg)
+	b.sb.WriteString(b.dialect.placeholder(b.argOffset + len(b.args)))
+}
+
+// clone 复制构造需要的设置，但是不复制构造的结果
+// Build 在 clone 出来的 builder 上构造，所以可以重复调用，也可以并发调用
+func (b *builder) clone() builder {
+	return builder{
+		core:    b.core,
+		dialect: b.dialect,
+		quoter:  b.quoter,
+		model:   b.model,
 	}
 }
 
+// buildSubquery 构造子查询，不包含括号
+// 子查询的参数会合并到当前的参数里面
+func (b *builder) buildSubquery(s SetOperand) error {
+	query, err := s.buildAt(b.argOffset + len(b.args))
+	if err != nil {
+		return err
+	}
+	b.sb.WriteString(query.SQL[:len(query.SQL)-1])
+	if len(query.Args) > 0 {
+		b.addArgs(query.Args...)
+	}
+	return nil
+}
+
 func (b *builder) addArgs(args ...any) {
 	if b.args == nil {
 		// 很少有查询能够超过八个参数
@@ -114,39 +232,58 @@ func (b *builder) buildExpression(e Expression) error {
 	case Aggregate:
 		return b.buildAggregate(exp, false)
 	case value:
-		b.sb.WriteByte('?')
-		b.addArgs(exp.val)
+		b.parameter(exp.val)
 	case RawExpr:
 		b.raw(exp)
 	case MathExpr:
-		return b.buildBinaryExpr(binaryExpr(exp))
+		return b.buildMathExpr(exp)
 	case Predicate:
 		return b.buildBinaryExpr(binaryExpr(exp))
 	case binaryExpr:
 		return b.buildBinaryExpr(exp)
 	case Subquery:
 		b.sb.WriteByte('(')
-		query, err := exp.s.Build()
-		if err != nil {
+		if err := b.buildSubquery(exp.s); err != nil {
 			return err
 		}
-		b.sb.WriteString(query.SQL[:len(query.SQL)-1])
 		b.sb.WriteByte(')')
 
 		//if exp.alias != "" {
 		//	b.sb.WriteString(" AS ")
 		//	b.quote(exp.alias)
 		//}
+	case valuesExpr:
+		b.sb.WriteByte('(')
+		for i, val := range exp.vals {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			b.parameter(val)
+		}
+		b.sb.WriteByte(')')
+	case betweenExpr:
+		if err := b.buildSubExpr(exp.lo); err != nil {
+			return err
+		}
+		b.sb.WriteString(" AND ")
+		return b.buildSubExpr(exp.hi)
+	case FuncExpr:
+		return b.dialect.buildFunc(b, exp)
+	case CaseExpr:
+		return b.buildCase(exp)
+	case WindowExpr:
+		return b.buildWindowExpr(exp)
+	case JSONPathExpr:
+		if !strings.HasPrefix(exp.path, "$") {
+			return errs.NewErrInvalidJSONPath(exp.path)
+		}
+		return b.dialect.buildJSONPath(b, exp)
 	case SubqueryExpr:
 		b.sb.WriteString(exp.pred + " ")
 		b.sb.WriteByte('(')
-
-		query, err := exp.s.s.Build()
-		if err != nil {
+		if err := b.buildSubquery(exp.s.s); err != nil {
 			return err
 		}
-		//fmt.Println("拿得到的，", query.SQL[:len(query.SQL)-1])
-		b.sb.WriteString(query.SQL[:len(query.SQL)-1])
 		b.sb.WriteByte(')')
 	default:
 		return errs.NewErrUnsupportedExpressionType(exp)
@@ -165,16 +302,111 @@ func (b *builder) buildBinaryExpr(e binaryExpr) error {
 	}
 	if e.right != nil {
 		b.sb.WriteByte(' ')
-		return b.buildSubExpr(e.right)
+		right := e.right
+		// 只有比较相等的时候才需要按照 JSON 编码，
+		// LIKE 的模式和大小比较的值编码之后就匹配不上了
+		switch e.op {
+		case opEQ, opNEQ, opIN, opNotIN:
+			right, err = b.jsonValue(e.left, e.right)
+			if err != nil {
+				return err
+			}
+		}
+		return b.buildSubExpr(right)
+	}
+	return nil
+}
+
+// buildFuncCall 按照 name(arg1,arg2) 的形式构造函数调用
+func (b *builder) buildFuncCall(name string, args []Expression) error {
+	b.sb.WriteString(name)
+	b.sb.WriteByte('(')
+	for i, arg := range args {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildExpression(arg); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildCase 构造 CASE WHEN ... THEN ... ELSE ... END
+func (b *builder) buildCase(c CaseExpr) error {
+	if len(c.whens) == 0 {
+		return errs.ErrEmptyCaseWhen
+	}
+	b.sb.WriteString("CASE")
+	for _, wt := range c.whens {
+		b.sb.WriteString(" WHEN ")
+		if err := b.buildExpression(wt.when); err != nil {
+			return err
+		}
+		b.sb.WriteString(" THEN ")
+		if err := b.buildExpression(wt.then); err != nil {
+			return err
+		}
+	}
+	if c.els != nil {
+		b.sb.WriteString(" ELSE ")
+		if err := b.buildExpression(c.els); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(" END")
+	return nil
+}
+
+// buildWindowExpr 构造 fn OVER (PARTITION BY ... ORDER BY ...)，不包括别名
+func (b *builder) buildWindowExpr(w WindowExpr) error {
+	if err := b.buildExpression(w.fn); err != nil {
+		return err
+	}
+	b.sb.WriteString(" OVER (")
+	for i, col := range w.window.partitionBy {
+		if i == 0 {
+			b.sb.WriteString("PARTITION BY ")
+		} else {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(col.table, col.name); err != nil {
+			return err
+		}
 	}
+	for i, ob := range w.window.orderBy {
+		if i == 0 {
+			if len(w.window.partitionBy) > 0 {
+				b.sb.WriteByte(' ')
+			}
+			b.sb.WriteString("ORDER BY ")
+		} else {
+			b.sb.WriteByte(',')
+		}
+		if err := b.dialect.buildOrderBy(b, ob); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
 	return nil
 }
 
+// buildMathExpr 构造算术表达式，不包括别名
+// 没有左边的是取负数
+func (b *builder) buildMathExpr(m MathExpr) error {
+	if m.left == nil {
+		b.sb.WriteString(m.op.String())
+		return b.buildSubExpr(m.right)
+	}
+	return b.buildBinaryExpr(m.binaryExpr)
+}
+
 func (b *builder) buildSubExpr(subExpr Expression) error {
 	switch sub := subExpr.(type) {
 	case MathExpr:
 		_ = b.sb.WriteByte('(')
-		if err := b.buildBinaryExpr(binaryExpr(sub)); err != nil {
+		if err := b.buildMathExpr(sub); err != nil {
 			return err
 		}
 		_ = b.sb.WriteByte(')')
@@ -201,6 +433,9 @@ func (b *builder) buildSubExpr(subExpr Expression) error {
 func (b *builder) buildAggregate(a Aggregate, useAlias bool) error {
 	b.sb.WriteString(a.fn)
 	b.sb.WriteByte('(')
+	if a.distinct {
+		b.sb.WriteString("DISTINCT ")
+	}
 	err := b.buildColumn(a.table, a.arg)
 	if err != nil {
 		return err
@@ -212,6 +447,143 @@ func (b *builder) buildAggregate(a Aggregate, useAlias bool) error {
 	return nil
 }
 
+// buildOrderBy 构造 ORDER BY 部分，包括前面的空格
+func (b *builder) buildOrderBy(obs []OrderBy) error {
+	b.sb.WriteString(" ORDER BY ")
+	for i, ob := range obs {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.dialect.buildOrderBy(b, ob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildOrderByExpr 构造排序项里面的表达式，排序项里面不使用别名
+func (b *builder) buildOrderByExpr(s Selectable) error {
+	switch exp := s.(type) {
+	case Column:
+		return b.buildColumn(exp.table, exp.name)
+	case Aggregate:
+		return b.buildAggregate(exp, false)
+	case RawExpr:
+		b.raw(exp)
+	case MathExpr:
+		return b.buildMathExpr(exp)
+	case FuncExpr:
+		return b.dialect.buildFunc(b, exp)
+	case CaseExpr:
+		return b.buildCase(exp)
+	case WindowExpr:
+		return b.buildWindowExpr(exp)
+	default:
+		return errs.NewErrUnsupportedExpressionType(exp)
+	}
+	return nil
+}
+
+// buildInsert 构造 INSERT INTO xxx(col1,col2) VALUES(?,?),(?,?)
+func (b *builder) buildInsert(ins insertValues) error {
+	b.sb.WriteString("INSERT INTO ")
+	b.quote(ins.table)
+	b.sb.WriteByte('(')
+	b.buildInsertColumns(ins.fields, "")
+	b.sb.WriteString(") VALUES")
+	return b.buildInsertRows(ins)
+}
+
+// buildInsertColumns 构造插入的列，table 不为空的时候会带上表名
+func (b *builder) buildInsertColumns(fields []*model.Field, table string) {
+	for idx, fd := range fields {
+		if idx > 0 {
+			b.sb.WriteByte(',')
+		}
+		if table != "" {
+			b.quote(table)
+			b.sb.WriteByte('.')
+		}
+		b.quote(fd.ColName)
+	}
+}
+
+// buildInsertRows 构造 (?,?),(?,?) 部分
+func (b *builder) buildInsertRows(ins insertValues) error {
+	for vIdx, val := range ins.values {
+		if vIdx > 0 {
+			b.sb.WriteByte(',')
+		}
+		b.sb.WriteByte('(')
+		for fIdx, field := range ins.fields {
+			if fIdx > 0 {
+				b.sb.WriteByte(',')
+			}
+			fdVal, err := val.Field(field.GoName)
+			if err != nil {
+				return err
+			}
+			b.parameter(fdVal)
+		}
+		b.sb.WriteByte(')')
+	}
+	return nil
+}
+
+// buildConflictColumns 构造冲突列，不包括括号
+func (b *builder) buildConflictColumns(cols []string) error {
+	for i, col := range cols {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(nil, col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildAssignment 构造 col=val，JSON 列的值会被编码成 JSON
+func (b *builder) buildAssignment(assign Assignment) error {
+	if err := b.buildColumn(nil, assign.column); err != nil {
+		return err
+	}
+	b.sb.WriteByte('=')
+	val, err := b.jsonValue(C(assign.column), assign.val)
+	if err != nil {
+		return err
+	}
+	return b.buildExpression(val)
+}
+
+// buildUpsertAssigns 构造 UPSERT 里面的赋值部分
+// Column 意味着使用插入的值来更新，插入的值怎么引用由 inserted 决定，
+// 例如 MySQL 是 VALUES(`col`)，SQLite 是 excluded.`col`
+func (b *builder) buildUpsertAssigns(assigns []Assignable, inserted func(colName string)) error {
+	for idx, a := range assigns {
+		if idx > 0 {
+			b.sb.WriteByte(',')
+		}
+		switch assign := a.(type) {
+		case Column:
+			colName, err := b.colName(assign.table, assign.name)
+			if err != nil {
+				return err
+			}
+			b.quote(colName)
+			b.sb.WriteByte('=')
+			inserted(colName)
+		case Assignment:
+			if err := b.buildAssignment(assign); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedAssignableType(a)
+		}
+	}
+	return nil
+}
+
 func (b *builder) buildAs(alias string) {
 	if alias != "" {
 		b.sb.WriteString(" AS ")
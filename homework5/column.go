@@ -23,10 +23,8 @@ func (c Column) target() TableReference {
 }
 
 func (c Column) As(alias string) Column {
-	return Column{
-		name:  c.name,
-		alias: alias,
-	}
+	c.alias = alias
+	return c
 }
 
 type value struct {
@@ -45,45 +43,42 @@ func C(name string) Column {
 	return Column{name: name}
 }
 
-func (c Column) Add(delta int) MathExpr {
-	return MathExpr{
-		left:  c,
-		op:    opAdd,
-		right: value{val: delta},
-	}
+// Add 例如 C("Age").Add(1)，或者 C("Balance").Add(C("Bonus"))
+func (c Column) Add(delta any) MathExpr {
+	return newMathExpr(c, opAdd, delta)
 }
 
-func (c Column) Multi(delta int) MathExpr {
-	return MathExpr{
-		left:  c,
-		op:    opAdd,
-		right: value{val: delta},
-	}
+func (c Column) Sub(delta any) MathExpr {
+	return newMathExpr(c, opSub, delta)
+}
+
+func (c Column) Multi(delta any) MathExpr {
+	return newMathExpr(c, opMulti, delta)
+}
+
+func (c Column) Div(delta any) MathExpr {
+	return newMathExpr(c, opDiv, delta)
+}
+
+func (c Column) Mod(delta any) MathExpr {
+	return newMathExpr(c, opMod, delta)
+}
+
+func (c Column) Neg() MathExpr {
+	return Neg(c)
 }
 
 // EQ 例如 C("id").EQ(12)
 func (c Column) EQ(arg any) Predicate {
-	return Predicate{
-		left:  c,
-		op:    opEQ,
-		right: exprOf(arg),
-	}
+	return newPredicate(c, opEQ, arg)
 }
 
 func (c Column) LT(arg any) Predicate {
-	return Predicate{
-		left:  c,
-		op:    opLT,
-		right: exprOf(arg),
-	}
+	return newPredicate(c, opLT, arg)
 }
 
 func (c Column) GT(arg any) Predicate {
-	return Predicate{
-		left:  c,
-		op:    opGT,
-		right: exprOf(arg),
-	}
+	return newPredicate(c, opGT, arg)
 }
 
 // In 有两种输入，一种是 IN 子查询
@@ -91,17 +86,59 @@ func (c Column) GT(arg any) Predicate {
 // 这里我们可以定义两个方法，如 In  和 InQuery，也可以定义一个方法
 // 这里我们使用一个方法
 func (c Column) In(vals ...any) Predicate {
+	return inPredicate(c, opIN, vals)
+}
+
+func (c Column) InQuery(sub Subquery) Predicate {
 	return Predicate{
 		left:  c,
 		op:    opIN,
-		right: valueOf(vals),
+		right: sub,
 	}
 }
 
-func (c Column) InQuery(sub Subquery) Predicate {
+func (c Column) NotIn(vals ...any) Predicate {
+	return inPredicate(c, opNotIN, vals)
+}
+
+func (c Column) NotInQuery(sub Subquery) Predicate {
 	return Predicate{
 		left:  c,
-		op:    opIN,
+		op:    opNotIN,
 		right: sub,
 	}
 }
+
+func (c Column) NEQ(arg any) Predicate {
+	return newPredicate(c, opNEQ, arg)
+}
+
+func (c Column) LTEQ(arg any) Predicate {
+	return newPredicate(c, opLTEQ, arg)
+}
+
+func (c Column) GTEQ(arg any) Predicate {
+	return newPredicate(c, opGTEQ, arg)
+}
+
+// Like 例如 C("Name").Like("Tom%")
+func (c Column) Like(pattern any) Predicate {
+	return newPredicate(c, opLike, pattern)
+}
+
+func (c Column) NotLike(pattern any) Predicate {
+	return newPredicate(c, opNotLike, pattern)
+}
+
+// Between 例如 C("Age").Between(18, 35)，包括两端
+func (c Column) Between(lo, hi any) Predicate {
+	return betweenPredicate(c, lo, hi)
+}
+
+func (c Column) IsNull() Predicate {
+	return nullPredicate(c, opIsNull)
+}
+
+func (c Column) IsNotNull() Predicate {
+	return nullPredicate(c, opIsNotNull)
+}
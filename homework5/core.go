@@ -17,8 +17,11 @@ package orm
 import (
 	"context"
 	"database/sql"
+	"exercise/geektime/homework5/version1/internal/errs"
 	"exercise/geektime/homework5/version1/internal/valuer"
 	"exercise/geektime/homework5/version1/model"
+	"reflect"
+	"time"
 )
 
 type core struct {
@@ -28,6 +31,46 @@ type core struct {
 	ms         []Middleware
 }
 
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// newRowScanner 返回把当前行扫描到 T 里面的方法
+// T 是结构体的时候按照列名和字段的映射来扫描，
+// T 是基本类型，time.Time 或者实现了 sql.Scanner 的时候直接扫描，
+// 例如 COUNT 查询，这时候结果集只能有一列
+func newRowScanner[T any](c core) (func(rows *sql.Rows) (*T, error), error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) ||
+		reflect.PointerTo(typ).Implements(scannerType) {
+		return func(rows *sql.Rows) (*T, error) {
+			cs, err := rows.Columns()
+			if err != nil {
+				return nil, err
+			}
+			if len(cs) != 1 {
+				return nil, errs.ErrTooManyReturnedColumns
+			}
+			tp := new(T)
+			if err = rows.Scan(tp); err != nil {
+				return nil, err
+			}
+			return tp, nil
+		}, nil
+	}
+
+	// 元数据只需要解析一次，每一行复用
+	meta, err := c.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	return func(rows *sql.Rows) (*T, error) {
+		tp := new(T)
+		if err := c.valCreator(tp, meta).SetColumns(rows); err != nil {
+			return nil, err
+		}
+		return tp, nil
+	}, nil
+}
+
 func getHandler[T any](ctx context.Context,
 	sess session,
 	c core,
@@ -44,25 +87,46 @@ func getHandler[T any](ctx context.Context,
 			Err: err,
 		}
 	}
+	defer func() {
+		_ = rows.Close()
+	}()
 
 	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
 		return &QueryResult{
 			Err: ErrNoRows,
 		}
 	}
 
-	tp := new(T)
-	meta, err := c.r.Get(tp)
+	scan, err := newRowScanner[T](c)
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	tp, err := scan(rows)
 	if err != nil {
 		return &QueryResult{
 			Err: err,
 		}
 	}
-	val := c.valCreator(tp, meta)
-	err = val.SetColumns(rows)
 	return &QueryResult{
 		Result: tp,
-		Err:    err,
+	}
+}
+
+// newQueryContext 构造 QueryContext，并且填充 T 对应的元数据
+// 原生查询里面 T 可能是 int 之类的基本类型，这种时候 Model 是 nil
+func newQueryContext[T any](c core, typ string, b QueryBuilder) *QueryContext {
+	m, _ := c.r.Get(new(T))
+	return &QueryContext{
+		Type:    typ,
+		Builder: b,
+		Model:   m,
 	}
 }
 
@@ -77,6 +141,117 @@ func get[T any](ctx context.Context, c core, sess session, qc *QueryContext) *Qu
 	return handler(ctx, qc)
 }
 
+func getMultiHandler[T any](ctx context.Context,
+	sess session,
+	c core,
+	qc *QueryContext) *QueryResult {
+	q, err := qc.Builder.Build()
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	scan, err := newRowScanner[T](c)
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+
+	res := make([]*T, 0, 8)
+	for rows.Next() {
+		tp, err := scan(rows)
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		res = append(res, tp)
+	}
+	if err = rows.Err(); err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	return &QueryResult{
+		Result: res,
+	}
+}
+
+func getMulti[T any](ctx context.Context, c core, sess session, qc *QueryContext) *QueryResult {
+	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		return getMultiHandler[T](ctx, sess, c, qc)
+	}
+	ms := c.ms
+	for i := len(ms) - 1; i >= 0; i-- {
+		handler = ms[i](handler)
+	}
+	return handler(ctx, qc)
+}
+
+// execReturning 执行带有 RETURNING 的语句
+// 返回的第 i 行会写回 vals[i] 里面
+func execReturning[T any](ctx context.Context, sess session, c core,
+	qc *QueryContext, vals []*T) Result {
+	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Builder.Build()
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		meta, err := c.r.Get(new(T))
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		var cnt int64
+		for int(cnt) < len(vals) && rows.Next() {
+			val := c.valCreator(vals[cnt], meta)
+			if err = val.SetColumns(rows); err != nil {
+				return &QueryResult{
+					Err: err,
+				}
+			}
+			cnt++
+		}
+		return &QueryResult{
+			Result: returningResult{affected: cnt},
+			Err:    rows.Err(),
+		}
+	}
+	ms := c.ms
+	for i := len(ms) - 1; i >= 0; i-- {
+		handler = ms[i](handler)
+	}
+	qr := handler(ctx, qc)
+	var res sql.Result
+	if qr.Result != nil {
+		res = qr.Result.(sql.Result)
+	}
+	return Result{err: qr.Err, res: res}
+}
+
 func exec(ctx context.Context, sess session, c core, qc *QueryContext) Result {
 	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
 		q, err := qc.Builder.Build()
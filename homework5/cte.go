@@ -0,0 +1,80 @@
+package orm
+
+// CTE 代表公共表表达式，也就是 WITH 子句里面的一个临时表
+// 它可以作为 TableReference 用在 From 和 Join 里面
+type CTE struct {
+	name      string
+	query     SetOperand
+	entity    any
+	columns   []Selectable
+	recursive bool
+}
+
+var _ TableReference = CTE{}
+
+// With 定义一个 CTE，列的校验和子查询一样，
+// 子查询指定了列的时候只能使用这些列
+func With(name string, sub Subquery) CTE {
+	return CTE{
+		name:    name,
+		query:   sub.s,
+		entity:  sub.entity,
+		columns: sub.columns,
+	}
+}
+
+// WithRecursive 定义一个递归的 CTE
+// 子查询里面引用 CTE 自身的时候使用 CTEOf 来构造
+func WithRecursive(name string, sub Subquery) CTE {
+	res := With(name, sub)
+	res.recursive = true
+	return res
+}
+
+// CTEOf 引用一个 CTE，它的列按照 entity 来解析
+// 一般用在递归 CTE 的子查询里面引用它自身
+func CTEOf(name string, entity any) CTE {
+	return CTE{
+		name:   name,
+		entity: entity,
+	}
+}
+
+func (c CTE) tableAlias() string {
+	return c.name
+}
+
+func (c CTE) C(name string) Column {
+	return Column{
+		table: c.asTable(),
+		name:  name,
+	}
+}
+
+func (c CTE) asTable() Table {
+	return TableOf(c.entity).As(c.name).Add(c.columns...)
+}
+
+func (c CTE) Join(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "JOIN",
+	}
+}
+
+func (c CTE) LeftJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (c CTE) RightJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "RIGHT JOIN",
+	}
+}
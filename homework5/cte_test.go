@@ -0,0 +1,121 @@
+package orm
+
+import (
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCTE_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	pgDB, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	type Employee struct {
+		Id        int64
+		ManagerId int64
+		Name      string
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "with",
+			q: func() QueryBuilder {
+				adults := With("adults", NewSelector[TestModel](db).
+					Select(C("Id"), C("Age")).Where(C("Age").GT(18)).AsSubquery("a"))
+				return NewSelector[TestModel](db).With(adults).
+					Select(adults.C("Id")).From(adults).Where(C("Age").LT(60))
+			}(),
+			wantQuery: &Query{
+				SQL:  "WITH `adults` AS (SELECT `id`,`age` FROM `test_model` WHERE `age` > ?) SELECT `adults`.`id` FROM `adults` WHERE `age` < ?;",
+				Args: []any{18, 60},
+			},
+		},
+		{
+			name: "join",
+			q: func() QueryBuilder {
+				adults := With("adults", NewSelector[TestModel](pgDB).
+					Select(C("Id")).Where(C("Age").GT(18)).AsSubquery("a"))
+				t1 := TableOf(&TestModel{}).As("t1")
+				return NewSelector[TestModel](pgDB).With(adults).
+					Select(t1.C("FirstName")).
+					From(t1.Join(adults).On(t1.C("Id").EQ(adults.C("Id")))).
+					Where(t1.C("LastName").IsNotNull()).Limit(10)
+			}(),
+			wantQuery: &Query{
+				SQL:  `WITH "adults" AS (SELECT "id" FROM "test_model" WHERE "age" > $1) SELECT "t1"."first_name" FROM ("test_model" AS "t1" JOIN "adults" ON "t1"."id" = "adults"."id") WHERE "t1"."last_name" IS NOT NULL LIMIT $2;`,
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name: "recursive",
+			q: func() QueryBuilder {
+				tree := CTEOf("tree", &Employee{})
+				e := TableOf(&Employee{}).As("e")
+				base := NewSelector[Employee](db).
+					Select(C("Id"), C("ManagerId"), C("Name")).Where(C("Id").EQ(1))
+				rec := NewSelector[Employee](db).
+					Select(e.C("Id"), e.C("ManagerId"), e.C("Name")).
+					From(e.Join(tree).On(e.C("ManagerId").EQ(tree.C("Id"))))
+				return NewSelector[Employee](db).
+					With(WithRecursive("tree", base.UnionAll(rec).AsSubquery("tree"))).
+					From(tree).Where(C("Name").Like("T%"))
+			}(),
+			wantQuery: &Query{
+				SQL:  "WITH RECURSIVE `tree` AS (SELECT `id`,`manager_id`,`name` FROM `employee` WHERE `id` = ? UNION ALL SELECT `e`.`id`,`e`.`manager_id`,`e`.`name` FROM (`employee` AS `e` JOIN `tree` ON `e`.`manager_id` = `tree`.`id`)) SELECT * FROM `tree` WHERE `name` LIKE ?;",
+				Args: []any{1, "T%"},
+			},
+		},
+		{
+			name: "multiple",
+			q: func() QueryBuilder {
+				young := With("young", NewSelector[TestModel](db).Where(C("Age").LT(18)).AsSubquery("y"))
+				old := With("old", NewSelector[TestModel](db).Where(C("Age").GT(60)).AsSubquery("o"))
+				return NewSelector[TestModel](db).With(young, old).From(young).
+					Union(NewSelector[TestModel](db).From(old))
+			}(),
+			wantQuery: &Query{
+				SQL:  "WITH `young` AS (SELECT * FROM `test_model` WHERE `age` < ?),`old` AS (SELECT * FROM `test_model` WHERE `age` > ?) SELECT * FROM `young` UNION SELECT * FROM `old`;",
+				Args: []any{18, 60},
+			},
+		},
+		{
+			// 子查询指定了列，只能使用这些列
+			name: "invalid column",
+			q: func() QueryBuilder {
+				adults := With("adults", NewSelector[TestModel](db).
+					Select(C("Id")).AsSubquery("a"))
+				return NewSelector[TestModel](db).With(adults).
+					Select(adults.C("FirstName")).From(adults)
+			}(),
+			wantErr: errs.NewErrUnknownField("FirstName"),
+		},
+		{
+			name: "undefined",
+			q: NewSelector[TestModel](db).With(CTEOf("tree", &TestModel{})).
+				From(CTEOf("tree", &TestModel{})),
+			wantErr: errs.NewErrUndefinedCTE("tree"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
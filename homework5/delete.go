@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"context"
+)
+
+// Deleter 用于构造 DELETE 语句
+type Deleter[T any] struct {
+	builder
+	table   string
+	where   []Predicate
+	orderBy []OrderBy
+	limit   int
+	sess    session
+}
+
+func NewDeleter[T any](sess session) *Deleter[T] {
+	c := sess.getCore()
+	return &Deleter[T]{
+		sess: sess,
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+	}
+}
+
+// From 指定表名，如果是空字符串，那么将会使用默认表名
+func (d *Deleter[T]) From(table string) *Deleter[T] {
+	d.table = table
+	return d
+}
+
+// Where 用于构造 WHERE 查询条件。如果 ps 长度为 0，那么不会构造 WHERE 部分
+// 注意，没有 WHERE 的 DELETE 会删除整张表的数据
+func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
+	d.where = ps
+	return d
+}
+
+// OrderBy 指定删除的顺序，一般和 Limit 一起使用
+// 这并不是标准 SQL，目前只有 MySQL 方言支持
+func (d *Deleter[T]) OrderBy(obs ...OrderBy) *Deleter[T] {
+	d.orderBy = obs
+	return d
+}
+
+// Limit 限制删除的行数
+// 这并不是标准 SQL，目前只有 MySQL 方言支持
+func (d *Deleter[T]) Limit(limit int) *Deleter[T] {
+	d.limit = limit
+	return d
+}
+
+// Clone 复制一个 Deleter，两者之后的修改互不影响
+func (d *Deleter[T]) Clone() *Deleter[T] {
+	return &Deleter[T]{
+		builder: d.builder.clone(),
+		table:   d.table,
+		where:   append([]Predicate(nil), d.where...),
+		orderBy: append([]OrderBy(nil), d.orderBy...),
+		limit:   d.limit,
+		sess:    d.sess,
+	}
+}
+
+// Build 在副本上构造，不修改 Deleter 本身，所以可以重复调用，也可以并发调用
+func (d *Deleter[T]) Build() (*Query, error) {
+	cp := *d
+	cp.builder = d.builder.clone()
+	return cp.build()
+}
+
+func (d *Deleter[T]) build() (*Query, error) {
+	var err error
+	if d.model == nil {
+		d.model, err = d.r.Get(new(T))
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.sb.WriteString("DELETE FROM ")
+	if d.table == "" {
+		d.quote(d.model.TableName)
+	} else {
+		d.quote(d.table)
+	}
+	if len(d.where) > 0 {
+		d.sb.WriteString(" WHERE ")
+		if err = d.buildPredicates(d.where); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.orderBy) > 0 || d.limit > 0 {
+		if err = d.dialect.buildDeleteOrderLimit(&d.builder, d.orderBy, d.limit); err != nil {
+			return nil, err
+		}
+	}
+	d.sb.WriteByte(';')
+	return &Query{
+		SQL:  d.sb.String(),
+		Args: d.args,
+	}, nil
+}
+
+func (d *Deleter[T]) Exec(ctx context.Context) Result {
+	return exec(ctx, d.sess, d.core, newQueryContext[T](d.core, "DELETE", d))
+}
@@ -0,0 +1,155 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDeleter_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	sqliteDB, err := OpenDB(mockdb, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "no where",
+			q:    NewDeleter[TestModel](db),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model`;",
+			},
+		},
+		{
+			name: "from",
+			q:    NewDeleter[TestModel](db).From("test_model_t"),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model_t`;",
+			},
+		},
+		{
+			name: "where",
+			q:    NewDeleter[TestModel](db).Where(C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "multiple predicates",
+			q: NewDeleter[TestModel](db).
+				Where(C("Age").GT(18), C("Age").LT(35)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE (`age` > ?) AND (`age` < ?);",
+				Args: []any{18, 35},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewDeleter[TestModel](db).Where(C("Invalid").EQ(16)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "mysql limit",
+			q:    NewDeleter[TestModel](db).Where(C("Age").GT(18)).Limit(10),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` > ? LIMIT ?;",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name: "mysql order by limit",
+			q: NewDeleter[TestModel](db).Where(C("Age").GT(18)).
+				OrderBy(Asc(C("Id"))).Limit(10),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` > ? ORDER BY `id` ASC LIMIT ?;",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name:    "sqlite order by",
+			q:       NewDeleter[TestModel](sqliteDB).OrderBy(Asc(C("Id"))),
+			wantErr: errs.NewErrUnsupportedByDialect("DELETE ... ORDER BY"),
+		},
+		{
+			name:    "sqlite limit",
+			q:       NewDeleter[TestModel](sqliteDB).Where(C("Age").GT(18)).Limit(10),
+			wantErr: errs.NewErrUnsupportedByDialect("DELETE ... LIMIT"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestDeleter_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var types []string
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			types = append(types, qc.Type)
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE FROM `test_model` WHERE `id` = ?").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM `test_model` WHERE `id` = ?").
+		WithArgs(2).WillReturnError(errors.New("exec error"))
+
+	res := NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	res = NewDeleter[TestModel](db).Where(C("Id").EQ(2)).Exec(context.Background())
+	assert.Equal(t, errors.New("exec error"), res.Err())
+
+	assert.Equal(t, []string{"DELETE", "DELETE"}, types)
+}
+
+func TestDeleter_Clone(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	base := NewDeleter[TestModel](db).Where(C("Age").GT(18))
+	q1, err := base.Build()
+	require.NoError(t, err)
+	q2, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "DELETE FROM `test_model` WHERE `age` > ?;",
+		Args: []any{18},
+	}, q2)
+	assert.Equal(t, q1, q2)
+
+	q, err := base.Clone().Limit(10).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM `test_model` WHERE `age` > ? LIMIT ?;", q.SQL)
+	q, err = base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q)
+}
@@ -1,30 +1,206 @@
 package orm
 
-import "exercise/geektime/homework5/version1/internal/errs"
+import (
+	"exercise/geektime/homework5/version1/internal/errs"
+	"strconv"
+	"strings"
+)
 
 var (
-	MySQL   Dialect = &mysqlDialect{}
-	SQLite3 Dialect = &sqlite3Dialect{}
+	MySQL    Dialect = &mysqlDialect{}
+	SQLite3  Dialect = &sqlite3Dialect{}
+	Postgres Dialect = &postgresDialect{}
+	// StandardSQL 是 ANSI SQL 方言，用于和标准比较接近的数据库
+	StandardSQL Dialect = &standardSQL{}
+)
+
+// lastInsertIdMode 描述批量插入的时候 sql.Result.LastInsertId 是哪一行的 ID
+type lastInsertIdMode int
+
+const (
+	// lastInsertIdNone 不能依赖 LastInsertId，自增主键只能通过 RETURNING 回填
+	lastInsertIdNone lastInsertIdMode = iota
+	// lastInsertIdFirst 是第一行的 ID，例如 MySQL
+	lastInsertIdFirst
+	// lastInsertIdLast 是最后一行的 ID，例如 SQLite
+	lastInsertIdLast
 )
 
 type Dialect interface {
 	// quoter 返回一个引号，引用列名，表名的引号
 	quoter() byte
-	// buildUpsert 构造插入冲突部分
-	buildUpsert(b *builder, odk *Upsert) error
+	// placeholder 返回第 idx 个参数的占位符，idx 从 1 开始
+	placeholder(idx int) string
+	// supportReturning 是否支持 INSERT ... RETURNING
+	supportReturning() bool
+	// lastInsertIdMode 用于回填自增主键
+	lastInsertIdMode() lastInsertIdMode
+	// buildUpsert 构造完整的 UPSERT 语句，不包括结尾的分号
+	// 有些方言是在 INSERT 后面追加冲突处理，有些方言则需要用 MERGE
+	buildUpsert(b *builder, ins insertValues, odk *Upsert) error
+	// buildDeleteOrderLimit 构造 DELETE 语句的 ORDER BY 和 LIMIT 部分
+	// 标准 SQL 并不支持，只有部分数据库例如 MySQL 支持
+	buildDeleteOrderLimit(b *builder, orderBy []OrderBy, limit int) error
+	// buildOrderBy 构造单个排序项
+	buildOrderBy(b *builder, ob OrderBy) error
+	// buildJSONPath 构造按照路径取 JSON 值的表达式，取出来的是文本
+	buildJSONPath(b *builder, j JSONPathExpr) error
+	// buildFunc 构造函数调用，负责转换不同数据库里面名字不一样的函数
+	buildFunc(b *builder, f FuncExpr) error
+	// supportSetOperation 是否支持 UNION，INTERSECT 这种集合操作
+	supportSetOperation(op setOperation) bool
+	// supportNestedSetOperation 集合操作的操作数能不能直接用括号括起来，
+	// 例如 a UNION (b EXCEPT c)。不支持的时候会改写成派生表
+	supportNestedSetOperation() bool
+	// maxParams 一条语句最多可以有多少个参数，0 意味着没有限制
+	// 批量插入的时候会按照它来拆分语句
+	maxParams() int
 }
 
+// standardSQL 是 ANSI SQL 的实现，同时也是其它方言的默认实现
+// 当数据库和标准差不多的时候，可以直接使用 StandardSQL
+// 标准无法表达的语法，会返回 ErrUnsupportedByDialect
 type standardSQL struct {
 }
 
 func (s *standardSQL) quoter() byte {
-	// TODO implement me
-	panic("implement me")
+	return '"'
+}
+
+func (s *standardSQL) placeholder(idx int) string {
+	return "?"
+}
+
+func (s *standardSQL) supportReturning() bool {
+	return false
+}
+
+func (s *standardSQL) lastInsertIdMode() lastInsertIdMode {
+	return lastInsertIdNone
+}
+
+// buildUpsert 标准 SQL 使用 MERGE 来表达 UPSERT，
+// 插入的数据作为 VALUES 派生表，别名为 src。
+// MERGE 必须通过冲突列来判断数据是否已经存在，所以必须指定冲突列
+func (s *standardSQL) buildUpsert(b *builder, ins insertValues, odk *Upsert) error {
+	if len(odk.conflictColumns) == 0 {
+		return errs.NewErrUnsupportedByDialect("MERGE 缺少冲突列")
+	}
+	b.sb.WriteString("MERGE INTO ")
+	b.quote(ins.table)
+	b.sb.WriteString(" USING (VALUES")
+	if err := b.buildInsertRows(ins); err != nil {
+		return err
+	}
+	b.sb.WriteString(") AS ")
+	b.quote("src")
+	b.sb.WriteByte('(')
+	b.buildInsertColumns(ins.fields, "")
+	b.sb.WriteString(") ON ")
+	for i, col := range odk.conflictColumns {
+		if i > 0 {
+			b.sb.WriteString(" AND ")
+		}
+		colName, err := b.colName(nil, col)
+		if err != nil {
+			return err
+		}
+		b.quote(ins.table)
+		b.sb.WriteByte('.')
+		b.quote(colName)
+		b.sb.WriteByte('=')
+		b.quote("src")
+		b.sb.WriteByte('.')
+		b.quote(colName)
+	}
+	if len(odk.assigns) > 0 {
+		b.sb.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		err := b.buildUpsertAssigns(odk.assigns, func(colName string) {
+			b.quote("src")
+			b.sb.WriteByte('.')
+			b.quote(colName)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	b.buildInsertColumns(ins.fields, "")
+	b.sb.WriteString(") VALUES (")
+	b.buildInsertColumns(ins.fields, "src")
+	b.sb.WriteByte(')')
+	return nil
 }
 
-func (s *standardSQL) buildUpsert(b *builder,
-	odk *Upsert) error {
-	panic("implement me")
+func (s *standardSQL) buildDeleteOrderLimit(b *builder, orderBy []OrderBy, limit int) error {
+	if len(orderBy) > 0 {
+		return errs.NewErrUnsupportedByDialect("DELETE ... ORDER BY")
+	}
+	return errs.NewErrUnsupportedByDialect("DELETE ... LIMIT")
+}
+
+func (s *standardSQL) buildOrderBy(b *builder, ob OrderBy) error {
+	if err := b.buildOrderByExpr(ob.expr); err != nil {
+		return err
+	}
+	b.sb.WriteByte(' ')
+	b.sb.WriteString(ob.order)
+	if ob.nulls != "" {
+		b.sb.WriteString(" NULLS ")
+		b.sb.WriteString(ob.nulls)
+	}
+	return nil
+}
+
+// buildJSONPath 标准 SQL 使用 JSON_VALUE
+func (s *standardSQL) buildJSONPath(b *builder, j JSONPathExpr) error {
+	b.sb.WriteString("JSON_VALUE(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sb.WriteByte(',')
+	b.parameter(j.path)
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildFunc 标准 SQL 里面当前时间是 CURRENT_TIMESTAMP，拼接字符串使用 ||
+func (s *standardSQL) buildFunc(b *builder, f FuncExpr) error {
+	switch strings.ToUpper(f.name) {
+	case "NOW":
+		b.sb.WriteString("CURRENT_TIMESTAMP")
+		return nil
+	case "CONCAT":
+		return s.buildConcat(b, f.args)
+	}
+	return b.buildFuncCall(f.name, f.args)
+}
+
+// buildConcat 构造 (a || b || c)
+func (s *standardSQL) buildConcat(b *builder, args []Expression) error {
+	b.sb.WriteByte('(')
+	for i, arg := range args {
+		if i > 0 {
+			b.sb.WriteString(" || ")
+		}
+		if err := b.buildSubExpr(arg); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+func (s *standardSQL) supportSetOperation(op setOperation) bool {
+	return true
+}
+
+func (s *standardSQL) supportNestedSetOperation() bool {
+	return true
+}
+
+func (s *standardSQL) maxParams() int {
+	return 0
 }
 
 type mysqlDialect struct {
@@ -35,37 +211,88 @@ func (m *mysqlDialect) quoter() byte {
 	return '`'
 }
 
-func (m *mysqlDialect) buildUpsert(b *builder,
-	odk *Upsert) error {
+// lastInsertIdMode MySQL 批量插入的时候返回的是第一行的 ID，
+// 后面的行依次加一，这要求 innodb_autoinc_lock_mode 不是 2 或者是单条语句插入
+func (m *mysqlDialect) lastInsertIdMode() lastInsertIdMode {
+	return lastInsertIdFirst
+}
+
+func (m *mysqlDialect) buildUpsert(b *builder, ins insertValues, odk *Upsert) error {
+	if err := b.buildInsert(ins); err != nil {
+		return err
+	}
 	b.sb.WriteString(" ON DUPLICATE KEY UPDATE ")
-	for idx, a := range odk.assigns {
-		if idx > 0 {
-			b.sb.WriteByte(',')
-		}
-		switch assign := a.(type) {
-		case Column:
-			colName, err := b.colName(assign.table, assign.name)
-			if err != nil {
-				return err
-			}
-			b.quote(colName)
-			b.sb.WriteString("=VALUES(")
-			b.quote(colName)
-			b.sb.WriteByte(')')
-		case Assignment:
-			err := b.buildColumn(nil, assign.column)
-			if err != nil {
-				return err
-			}
-			b.sb.WriteString("=")
-			return b.buildExpression(assign.val)
-		default:
-			return errs.NewErrUnsupportedAssignableType(a)
+	return b.buildUpsertAssigns(odk.assigns, func(colName string) {
+		b.sb.WriteString("VALUES(")
+		b.quote(colName)
+		b.sb.WriteByte(')')
+	})
+}
+
+func (m *mysqlDialect) buildDeleteOrderLimit(b *builder, orderBy []OrderBy, limit int) error {
+	if len(orderBy) > 0 {
+		if err := b.buildOrderBy(orderBy); err != nil {
+			return err
 		}
 	}
+	if limit > 0 {
+		b.sb.WriteString(" LIMIT ")
+		b.parameter(limit)
+	}
+	return nil
+}
+
+// buildOrderBy MySQL 不支持 NULLS FIRST 和 NULLS LAST
+// 所以我们先按照 expr IS NULL 排序来模拟。
+// MySQL 里面 expr IS NULL 的结果是 0 或者 1
+func (m *mysqlDialect) buildOrderBy(b *builder, ob OrderBy) error {
+	if ob.nulls == "" {
+		return m.standardSQL.buildOrderBy(b, ob)
+	}
+	if err := b.buildOrderByExpr(ob.expr); err != nil {
+		return err
+	}
+	if ob.nulls == "FIRST" {
+		b.sb.WriteString(" IS NULL DESC,")
+	} else {
+		b.sb.WriteString(" IS NULL ASC,")
+	}
+	ob.nulls = ""
+	return m.standardSQL.buildOrderBy(b, ob)
+}
+
+// buildJSONPath JSON_EXTRACT 返回的是 JSON，字符串会带上引号，所以要 JSON_UNQUOTE
+func (m *mysqlDialect) buildJSONPath(b *builder, j JSONPathExpr) error {
+	b.sb.WriteString("JSON_UNQUOTE(JSON_EXTRACT(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sb.WriteByte(',')
+	b.parameter(j.path)
+	b.sb.WriteString("))")
 	return nil
 }
 
+// buildFunc MySQL 支持 NOW() 和 CONCAT()，直接生成
+// 注意 MySQL 里面的 || 默认是逻辑或
+func (m *mysqlDialect) buildFunc(b *builder, f FuncExpr) error {
+	return b.buildFuncCall(f.name, f.args)
+}
+
+// supportSetOperation MySQL 8.0.31 之前只支持 UNION
+func (m *mysqlDialect) supportSetOperation(op setOperation) bool {
+	return op == setUnion || op == setUnionAll
+}
+
+// supportNestedSetOperation MySQL 8.0.31 之前不支持带括号的操作数
+func (m *mysqlDialect) supportNestedSetOperation() bool {
+	return false
+}
+
+func (m *mysqlDialect) maxParams() int {
+	return 65535
+}
+
 type sqlite3Dialect struct {
 	standardSQL
 }
@@ -74,46 +301,106 @@ func (s *sqlite3Dialect) quoter() byte {
 	return '`'
 }
 
-func (s *sqlite3Dialect) buildUpsert(b *builder, odk *Upsert) error {
+// supportReturning SQLite 从 3.35 开始支持 RETURNING
+func (s *sqlite3Dialect) supportReturning() bool {
+	return true
+}
+
+func (s *sqlite3Dialect) lastInsertIdMode() lastInsertIdMode {
+	return lastInsertIdLast
+}
+
+func (s *sqlite3Dialect) buildUpsert(b *builder, ins insertValues, odk *Upsert) error {
+	if err := b.buildInsert(ins); err != nil {
+		return err
+	}
 	b.sb.WriteString(" ON CONFLICT")
 	if len(odk.conflictColumns) > 0 {
 		b.sb.WriteByte('(')
-		for i, col := range odk.conflictColumns {
-			if i > 0 {
-				b.sb.WriteByte(',')
-			}
-			err := b.buildColumn(nil, col)
-			if err != nil {
-				return err
-			}
+		if err := b.buildConflictColumns(odk.conflictColumns); err != nil {
+			return err
 		}
 		b.sb.WriteByte(')')
 	}
 	b.sb.WriteString(" DO UPDATE SET ")
+	return b.buildUpsertAssigns(odk.assigns, func(colName string) {
+		b.sb.WriteString("excluded.")
+		b.quote(colName)
+	})
+}
 
-	for idx, a := range odk.assigns {
-		if idx > 0 {
-			b.sb.WriteByte(',')
-		}
-		switch assign := a.(type) {
-		case Column:
-			colName, err := b.colName(assign.table, assign.name)
-			if err != nil {
-				return err
-			}
-			b.quote(colName)
-			b.sb.WriteString("=excluded.")
-			b.quote(colName)
-		case Assignment:
-			err := b.buildColumn(nil, assign.column)
-			if err != nil {
-				return err
-			}
-			b.sb.WriteString("=")
-			return b.buildExpression(assign.val)
-		default:
-			return errs.NewErrUnsupportedAssignableType(a)
+func (s *sqlite3Dialect) buildJSONPath(b *builder, j JSONPathExpr) error {
+	b.sb.WriteString("json_extract(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sb.WriteByte(',')
+	b.parameter(j.path)
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// supportSetOperation SQLite 的 INTERSECT 和 EXCEPT 不支持 ALL
+func (s *sqlite3Dialect) supportSetOperation(op setOperation) bool {
+	return op != setIntersectAll && op != setExceptAll
+}
+
+// supportNestedSetOperation SQLite 不支持带括号的操作数
+func (s *sqlite3Dialect) supportNestedSetOperation() bool {
+	return false
+}
+
+// maxParams SQLite 3.32.0 之前默认是 999，之后是 32766
+// 这里按照旧版本来，新版本可以通过 BatchSize 插入更多的行
+func (s *sqlite3Dialect) maxParams() int {
+	return 999
+}
+
+type postgresDialect struct {
+	standardSQL
+}
+
+// placeholder PostgreSQL 使用 $1, $2 这种带编号的占位符
+func (p *postgresDialect) placeholder(idx int) string {
+	return "$" + strconv.Itoa(idx)
+}
+
+func (p *postgresDialect) supportReturning() bool {
+	return true
+}
+
+func (p *postgresDialect) buildUpsert(b *builder, ins insertValues, odk *Upsert) error {
+	if err := b.buildInsert(ins); err != nil {
+		return err
+	}
+	b.sb.WriteString(" ON CONFLICT")
+	if len(odk.conflictColumns) > 0 {
+		b.sb.WriteString(" (")
+		if err := b.buildConflictColumns(odk.conflictColumns); err != nil {
+			return err
 		}
+		b.sb.WriteByte(')')
 	}
+	b.sb.WriteString(" DO UPDATE SET ")
+	return b.buildUpsertAssigns(odk.assigns, func(colName string) {
+		b.sb.WriteString("EXCLUDED.")
+		b.quote(colName)
+	})
+}
+
+// buildJSONPath PostgreSQL 使用 #>> 操作符，路径是一个文本数组，
+// 例如 $.tags[0] 对应 {tags,0}
+func (p *postgresDialect) buildJSONPath(b *builder, j JSONPathExpr) error {
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sb.WriteString(" #>> ")
+	path := strings.NewReplacer("[", ".", "]", "").Replace(j.path[1:])
+	keys := strings.FieldsFunc(path, func(r rune) bool { return r == '.' })
+	b.parameter("{" + strings.Join(keys, ",") + "}")
 	return nil
 }
+
+func (p *postgresDialect) maxParams() int {
+	return 65535
+}
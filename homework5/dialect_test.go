@@ -0,0 +1,348 @@
+package orm
+
+import (
+	"context"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPostgres_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+	mysqlDB, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type OrderDetail struct {
+		OrderId int
+		ItemId  int
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18), C("FirstName").EQ("Tom")).
+				Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE ("age" > $1) AND ("first_name" = $2) LIMIT $3 OFFSET $4;`,
+				Args: []any{18, "Tom", 10, 20},
+			},
+		},
+		{
+			// RawExpr 里面的 ? 会被转换
+			name: "raw expression",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").EQ(1), Raw(`"age" BETWEEN ? AND ?`, 18, 35).AsPredicate()),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE ("id" = $1) AND ("age" BETWEEN $2 AND $3);`,
+				Args: []any{1, 18, 35},
+			},
+		},
+		{
+			// 子查询的占位符编号接着外层查询往下
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).
+					Where(C("ItemId").GT(100)).AsSubquery("sub")
+				return NewSelector[TestModel](db).
+					Where(C("Age").GT(18), C("Id").InQuery(sub), C("Id").LT(1000))
+			}(),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE (("age" > $1) AND ("id" IN (SELECT "order_id" FROM "order_detail" WHERE "item_id" > $2))) AND ("id" < $3);`,
+				Args: []any{18, 100, 1000},
+			},
+		},
+		{
+			name: "update",
+			q: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+				Set(C("Age"), Assign("FirstName", "Tom")).Where(C("Id").EQ(12)),
+			wantQuery: &Query{
+				SQL:  `UPDATE "test_model" SET "age"=$1,"first_name"=$2 WHERE "id" = $3;`,
+				Args: []any{int8(18), "Tom", 12},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName", "Age").
+				Values(&TestModel{Id: 1, FirstName: "Tom", Age: 18}, &TestModel{Id: 2, FirstName: "Jerry", Age: 16}).
+				OnDuplicateKey().ConflictColumns("Id").
+				Update(C("FirstName"), Assign("Age", 20)),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","first_name","age") VALUES($1,$2,$3),($4,$5,$6)` +
+					` ON CONFLICT ("id") DO UPDATE SET "first_name"=EXCLUDED."first_name","age"=$7;`,
+				Args: []any{int64(1), "Tom", int8(18), int64(2), "Jerry", int8(16), 20},
+			},
+		},
+		{
+			name: "returning",
+			q: NewInserter[TestModel](db).Columns("FirstName", "Age").
+				Values(&TestModel{FirstName: "Tom", Age: 18}).Returning("Id"),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("first_name","age") VALUES($1,$2) RETURNING "id";`,
+				Args: []any{"Tom", int8(18)},
+			},
+		},
+		{
+			name: "mysql returning",
+			q: NewInserter[TestModel](mysqlDB).Columns("FirstName", "Age").
+				Values(&TestModel{FirstName: "Tom", Age: 18}).Returning("Id"),
+			wantErr: errs.NewErrUnsupportedByDialect("RETURNING"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_Returning(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id"})
+	rows.AddRow(int64(11))
+	rows.AddRow(int64(12))
+	mock.ExpectQuery(`INSERT INTO "test_model"\("first_name"\) VALUES\(\$1\),\(\$2\) RETURNING "id";`).
+		WithArgs("Tom", "Jerry").WillReturnRows(rows)
+
+	vals := []*TestModel{{FirstName: "Tom"}, {FirstName: "Jerry"}}
+	res := NewInserter[TestModel](db).Columns("FirstName").
+		Values(vals...).Returning("Id").Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []*TestModel{
+		{Id: 11, FirstName: "Tom"},
+		{Id: 12, FirstName: "Jerry"},
+	}, vals)
+}
+
+func TestUpsert_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	mysqlDB, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	sqliteDB, err := OpenDB(mockdb, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+	standardDB, err := OpenDB(mockdb, DBWithDialect(StandardSQL))
+	require.NoError(t, err)
+
+	vals := []*TestModel{
+		{Id: 1, FirstName: "Tom", Age: 18},
+		{Id: 2, FirstName: "Jerry", Age: 16},
+	}
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "mysql",
+			q: NewInserter[TestModel](mysqlDB).Columns("Id", "FirstName", "Age").
+				Values(vals...).OnDuplicateKey().
+				Update(C("FirstName"), Assign("Age", 20)),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES(?,?,?),(?,?,?)" +
+					" ON DUPLICATE KEY UPDATE `first_name`=VALUES(`first_name`),`age`=?;",
+				Args: []any{int64(1), "Tom", int8(18), int64(2), "Jerry", int8(16), 20},
+			},
+		},
+		{
+			name: "sqlite",
+			q: NewInserter[TestModel](sqliteDB).Columns("Id", "FirstName", "Age").
+				Values(vals...).OnDuplicateKey().ConflictColumns("Id").
+				Update(Assign("Age", 20), C("FirstName")),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES(?,?,?),(?,?,?)" +
+					" ON CONFLICT(`id`) DO UPDATE SET `age`=?,`first_name`=excluded.`first_name`;",
+				Args: []any{int64(1), "Tom", int8(18), int64(2), "Jerry", int8(16), 20},
+			},
+		},
+		{
+			name: "standard merge",
+			q: NewInserter[TestModel](standardDB).Columns("Id", "FirstName", "Age").
+				Values(vals...).OnDuplicateKey().ConflictColumns("Id").
+				Update(C("FirstName"), Assign("Age", 20)),
+			wantQuery: &Query{
+				SQL: `MERGE INTO "test_model" USING (VALUES(?,?,?),(?,?,?)) AS "src"("id","first_name","age")` +
+					` ON "test_model"."id"="src"."id"` +
+					` WHEN MATCHED THEN UPDATE SET "first_name"="src"."first_name","age"=?` +
+					` WHEN NOT MATCHED THEN INSERT ("id","first_name","age") VALUES ("src"."id","src"."first_name","src"."age");`,
+				Args: []any{int64(1), "Tom", int8(18), int64(2), "Jerry", int8(16), 20},
+			},
+		},
+		{
+			name: "standard merge without conflict columns",
+			q: NewInserter[TestModel](standardDB).Columns("Id", "FirstName").
+				Values(vals...).OnDuplicateKey().Update(C("FirstName")),
+			wantErr: errs.NewErrUnsupportedByDialect("MERGE 缺少冲突列"),
+		},
+		{
+			name: "standard select",
+			q:    NewSelector[TestModel](standardDB).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE "id" = ?;`,
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "standard delete limit",
+			q:       NewDeleter[TestModel](standardDB).Limit(1),
+			wantErr: errs.NewErrUnsupportedByDialect("DELETE ... LIMIT"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestJSONPath_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+
+	type Product struct {
+		Id    int64
+		Attrs map[string]any `orm:"json"`
+	}
+
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		path      string
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			path:    "$.color",
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) = ?;",
+				Args: []any{"$.color", "red"},
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			path:    "$.color",
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE json_extract(`attrs`,?) = ?;",
+				Args: []any{"$.color", "red"},
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			path:    "$.sizes[0].color",
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "product" WHERE "attrs" #>> $1 = $2;`,
+				Args: []any{"{sizes,0,color}", "red"},
+			},
+		},
+		{
+			name:    "standard",
+			dialect: StandardSQL,
+			path:    "$.color",
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "product" WHERE JSON_VALUE("attrs",?) = ?;`,
+				Args: []any{"$.color", "red"},
+			},
+		},
+		{
+			name:    "invalid path",
+			dialect: MySQL,
+			path:    "color",
+			wantErr: errs.NewErrInvalidJSONPath("color"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := OpenDB(mockdb, DBWithDialect(tc.dialect))
+			require.NoError(t, err)
+			q, err := NewSelector[Product](db).
+				Where(C("Attrs").JSONPath(tc.path).EQ("red")).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestFunc_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		wantQuery *Query
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			wantQuery: &Query{
+				SQL:  "SELECT CONCAT(`first_name`,?,`last_name`) AS `full_name` FROM `test_model` WHERE `age` < NOW();",
+				Args: []any{" "},
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			wantQuery: &Query{
+				SQL:  "SELECT (`first_name` || ? || `last_name`) AS `full_name` FROM `test_model` WHERE `age` < CURRENT_TIMESTAMP;",
+				Args: []any{" "},
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			wantQuery: &Query{
+				SQL:  `SELECT ("first_name" || $1 || "last_name") AS "full_name" FROM "test_model" WHERE "age" < CURRENT_TIMESTAMP;`,
+				Args: []any{" "},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := OpenDB(mockdb, DBWithDialect(tc.dialect))
+			require.NoError(t, err)
+			q, err := NewSelector[TestModel](db).
+				Select(Concat(C("FirstName"), " ", C("LastName")).As("full_name")).
+				Where(C("Age").LT(Now())).Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
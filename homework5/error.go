@@ -6,4 +6,6 @@ import "exercise/geektime/homework5/version1/internal/errs"
 var (
 	// ErrNoRows 代表没有找到数据
 	ErrNoRows = errs.ErrNoRows
+	// ErrUnsupportedByDialect 代表当前方言无法表达构造的语句
+	ErrUnsupportedByDialect = errs.ErrUnsupportedByDialect
 )
@@ -45,26 +45,130 @@ type binaryExpr struct {
 
 func (binaryExpr) expr() {}
 
-type MathExpr binaryExpr
+// MathExpr 代表算术表达式，例如 C("Age").Add(1)
+// 它既可以用在 WHERE 和 UPDATE 里面，也可以通过 As 指定别名之后出现在 SELECT 里面
+type MathExpr struct {
+	binaryExpr
+	alias string
+}
 
-func (m MathExpr) Add(val interface{}) MathExpr {
+// newMathExpr 构造 left op right，right 可以是值，也可以是 Expression，
+// 例如 C("Balance").Sub(C("Hold"))
+func newMathExpr(left Expression, o op, right any) MathExpr {
 	return MathExpr{
-		left:  m,
-		op:    opAdd,
-		right: valueOf(val),
+		binaryExpr: binaryExpr{
+			left:  left,
+			op:    o,
+			right: exprOf(right),
+		},
 	}
 }
 
-func (m MathExpr) Multi(val interface{}) MathExpr {
+// Neg 取负数，例如 Neg(C("Balance")) 会生成 -`balance`
+func Neg(e Expression) MathExpr {
 	return MathExpr{
-		left:  m,
-		op:    opMulti,
-		right: valueOf(val),
+		binaryExpr: binaryExpr{
+			op:    opSub,
+			right: e,
+		},
 	}
 }
 
+func (m MathExpr) Add(val interface{}) MathExpr {
+	return newMathExpr(m, opAdd, val)
+}
+
+func (m MathExpr) Sub(val interface{}) MathExpr {
+	return newMathExpr(m, opSub, val)
+}
+
+func (m MathExpr) Multi(val interface{}) MathExpr {
+	return newMathExpr(m, opMulti, val)
+}
+
+func (m MathExpr) Div(val interface{}) MathExpr {
+	return newMathExpr(m, opDiv, val)
+}
+
+func (m MathExpr) Mod(val interface{}) MathExpr {
+	return newMathExpr(m, opMod, val)
+}
+
+func (m MathExpr) Neg() MathExpr {
+	return Neg(m)
+}
+
+func (m MathExpr) As(alias string) MathExpr {
+	m.alias = alias
+	return m
+}
+
+func (m MathExpr) selectedAlias() string {
+	return m.alias
+}
+
+func (m MathExpr) fieldName() string {
+	return ""
+}
+
+func (m MathExpr) target() TableReference {
+	return nil
+}
+
 func (m MathExpr) expr() {}
 
+func (m MathExpr) EQ(arg any) Predicate {
+	return newPredicate(m, opEQ, arg)
+}
+
+func (m MathExpr) NEQ(arg any) Predicate {
+	return newPredicate(m, opNEQ, arg)
+}
+
+func (m MathExpr) LT(arg any) Predicate {
+	return newPredicate(m, opLT, arg)
+}
+
+func (m MathExpr) LTEQ(arg any) Predicate {
+	return newPredicate(m, opLTEQ, arg)
+}
+
+func (m MathExpr) GT(arg any) Predicate {
+	return newPredicate(m, opGT, arg)
+}
+
+func (m MathExpr) GTEQ(arg any) Predicate {
+	return newPredicate(m, opGTEQ, arg)
+}
+
+func (m MathExpr) In(vals ...any) Predicate {
+	return inPredicate(m, opIN, vals)
+}
+
+func (m MathExpr) NotIn(vals ...any) Predicate {
+	return inPredicate(m, opNotIN, vals)
+}
+
+func (m MathExpr) Like(pattern any) Predicate {
+	return newPredicate(m, opLike, pattern)
+}
+
+func (m MathExpr) NotLike(pattern any) Predicate {
+	return newPredicate(m, opNotLike, pattern)
+}
+
+func (m MathExpr) Between(lo, hi any) Predicate {
+	return betweenPredicate(m, lo, hi)
+}
+
+func (m MathExpr) IsNull() Predicate {
+	return nullPredicate(m, opIsNull)
+}
+
+func (m MathExpr) IsNotNull() Predicate {
+	return nullPredicate(m, opIsNotNull)
+}
+
 // SubqueryExpr 注意，这个谓词这种不是在所有的数据库里面都支持的
 // 这里采取的是和 Upsert 不同的做法
 // Upsert 里面我们是属于用 dialect 来区别不同的实现
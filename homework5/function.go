@@ -0,0 +1,220 @@
+package orm
+
+// FuncExpr 代表函数调用，例如 Fn("COALESCE", C("Nick"), C("Name"))
+// 参数可以是 Expression，例如列，也可以是值，值会作为查询参数。
+// 函数怎么生成由方言决定，在不同数据库里面名字不一样的函数会被转换，
+// 例如 NOW() 在 SQLite 里面是 CURRENT_TIMESTAMP
+type FuncExpr struct {
+	name  string
+	args  []Expression
+	alias string
+}
+
+// Fn 构造一个函数调用，name 会原样输出
+func Fn(name string, args ...any) FuncExpr {
+	exprs := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, exprOf(arg))
+	}
+	return FuncExpr{
+		name: name,
+		args: exprs,
+	}
+}
+
+// Coalesce 返回第一个不是 NULL 的参数
+func Coalesce(args ...any) FuncExpr {
+	return Fn("COALESCE", args...)
+}
+
+func Lower(arg any) FuncExpr {
+	return Fn("LOWER", arg)
+}
+
+func Upper(arg any) FuncExpr {
+	return Fn("UPPER", arg)
+}
+
+// Concat 拼接字符串，SQLite 和标准 SQL 里面使用 ||
+func Concat(args ...any) FuncExpr {
+	return Fn("CONCAT", args...)
+}
+
+// Now 当前时间，SQLite 和标准 SQL 里面使用 CURRENT_TIMESTAMP
+func Now() FuncExpr {
+	return Fn("NOW")
+}
+
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) selectedAlias() string {
+	return f.alias
+}
+
+func (f FuncExpr) fieldName() string {
+	return ""
+}
+
+func (f FuncExpr) target() TableReference {
+	return nil
+}
+
+func (f FuncExpr) expr() {}
+
+func (f FuncExpr) EQ(arg any) Predicate {
+	return newPredicate(f, opEQ, arg)
+}
+
+func (f FuncExpr) NEQ(arg any) Predicate {
+	return newPredicate(f, opNEQ, arg)
+}
+
+func (f FuncExpr) LT(arg any) Predicate {
+	return newPredicate(f, opLT, arg)
+}
+
+func (f FuncExpr) LTEQ(arg any) Predicate {
+	return newPredicate(f, opLTEQ, arg)
+}
+
+func (f FuncExpr) GT(arg any) Predicate {
+	return newPredicate(f, opGT, arg)
+}
+
+func (f FuncExpr) GTEQ(arg any) Predicate {
+	return newPredicate(f, opGTEQ, arg)
+}
+
+func (f FuncExpr) Like(pattern any) Predicate {
+	return newPredicate(f, opLike, pattern)
+}
+
+func (f FuncExpr) NotLike(pattern any) Predicate {
+	return newPredicate(f, opNotLike, pattern)
+}
+
+func (f FuncExpr) In(vals ...any) Predicate {
+	return inPredicate(f, opIN, vals)
+}
+
+func (f FuncExpr) NotIn(vals ...any) Predicate {
+	return inPredicate(f, opNotIN, vals)
+}
+
+func (f FuncExpr) Between(lo, hi any) Predicate {
+	return betweenPredicate(f, lo, hi)
+}
+
+func (f FuncExpr) IsNull() Predicate {
+	return nullPredicate(f, opIsNull)
+}
+
+func (f FuncExpr) IsNotNull() Predicate {
+	return nullPredicate(f, opIsNotNull)
+}
+
+// CaseExpr 代表 CASE WHEN 表达式
+// 例如 Case().When(C("Age").LT(18), "child").Else("adult")
+type CaseExpr struct {
+	whens []whenThen
+	els   Expression
+	alias string
+}
+
+type whenThen struct {
+	when Predicate
+	then Expression
+}
+
+func Case() CaseExpr {
+	return CaseExpr{}
+}
+
+// When 增加一个分支，then 可以是值，也可以是 Expression
+func (c CaseExpr) When(when Predicate, then any) CaseExpr {
+	// 避免和之前的 CaseExpr 共享底层数组
+	whens := make([]whenThen, 0, len(c.whens)+1)
+	whens = append(whens, c.whens...)
+	c.whens = append(whens, whenThen{when: when, then: exprOf(then)})
+	return c
+}
+
+// Else 所有的分支都不满足的时候的值，不调用就是 NULL
+func (c CaseExpr) Else(val any) CaseExpr {
+	c.els = exprOf(val)
+	return c
+}
+
+func (c CaseExpr) As(alias string) CaseExpr {
+	c.alias = alias
+	return c
+}
+
+func (c CaseExpr) selectedAlias() string {
+	return c.alias
+}
+
+func (c CaseExpr) fieldName() string {
+	return ""
+}
+
+func (c CaseExpr) target() TableReference {
+	return nil
+}
+
+func (c CaseExpr) expr() {}
+
+func (c CaseExpr) EQ(arg any) Predicate {
+	return newPredicate(c, opEQ, arg)
+}
+
+func (c CaseExpr) NEQ(arg any) Predicate {
+	return newPredicate(c, opNEQ, arg)
+}
+
+func (c CaseExpr) LT(arg any) Predicate {
+	return newPredicate(c, opLT, arg)
+}
+
+func (c CaseExpr) LTEQ(arg any) Predicate {
+	return newPredicate(c, opLTEQ, arg)
+}
+
+func (c CaseExpr) GT(arg any) Predicate {
+	return newPredicate(c, opGT, arg)
+}
+
+func (c CaseExpr) GTEQ(arg any) Predicate {
+	return newPredicate(c, opGTEQ, arg)
+}
+
+func (c CaseExpr) Like(pattern any) Predicate {
+	return newPredicate(c, opLike, pattern)
+}
+
+func (c CaseExpr) NotLike(pattern any) Predicate {
+	return newPredicate(c, opNotLike, pattern)
+}
+
+func (c CaseExpr) In(vals ...any) Predicate {
+	return inPredicate(c, opIN, vals)
+}
+
+func (c CaseExpr) NotIn(vals ...any) Predicate {
+	return inPredicate(c, opNotIN, vals)
+}
+
+func (c CaseExpr) Between(lo, hi any) Predicate {
+	return betweenPredicate(c, lo, hi)
+}
+
+func (c CaseExpr) IsNull() Predicate {
+	return nullPredicate(c, opIsNull)
+}
+
+func (c CaseExpr) IsNotNull() Predicate {
+	return nullPredicate(c, opIsNotNull)
+}
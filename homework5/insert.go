@@ -3,6 +3,7 @@ package orm
 import (
 	"context"
 	"exercise/geektime/homework5/version1/internal/errs"
+	"exercise/geektime/homework5/version1/internal/valuer"
 	"exercise/geektime/homework5/version1/model"
 )
 
@@ -30,12 +31,23 @@ func (o *UpsertBuilder[T]) Update(assigns ...Assignable) *Inserter[T] {
 	return o.i
 }
 
+// insertValues 是 INSERT 语句里面和方言无关的部分
+// 方言利用它来组装完整的 UPSERT 语句
+type insertValues struct {
+	table  string
+	fields []*model.Field
+	values []valuer.Value
+}
+
 type Inserter[T any] struct {
 	builder
-	values  []*T
-	columns []string
-	upsert  *Upsert
-	sess    session
+	values    []*T
+	columns   []string
+	upsert    *Upsert
+	returning []string
+	sess      session
+	// batchSize 每条语句最多插入多少行，0 意味着按照方言的参数限制来计算
+	batchSize int
 }
 
 func NewInserter[T any](sess session) *Inserter[T] {
@@ -50,6 +62,20 @@ func NewInserter[T any](sess session) *Inserter[T] {
 	}
 }
 
+// Clone 复制一个 Inserter，两者之后的修改互不影响
+// 插入的对象本身是共享的
+func (i *Inserter[T]) Clone() *Inserter[T] {
+	return &Inserter[T]{
+		builder:   i.builder.clone(),
+		values:    append([]*T(nil), i.values...),
+		columns:   append([]string(nil), i.columns...),
+		upsert:    i.upsert,
+		returning: append([]string(nil), i.returning...),
+		sess:      i.sess,
+		batchSize: i.batchSize,
+	}
+}
+
 func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
 	i.values = vals
 	return i
@@ -61,7 +87,8 @@ func (i *Inserter[T]) OnDuplicateKey() *UpsertBuilder[T] {
 	}
 }
 
-// Fields 指定要插入的列
+// Columns 指定要插入的列
+// 如果没有指定，那么会插入除了自增列以外的全部列
 // TODO 目前我们只支持指定具体的列，但是不支持复杂的表达式
 // 例如不支持 VALUES(..., now(), now()) 这种在 MySQL 里面常用的
 func (i *Inserter[T]) Columns(cols ...string) *Inserter[T] {
@@ -69,7 +96,30 @@ func (i *Inserter[T]) Columns(cols ...string) *Inserter[T] {
 	return i
 }
 
+// Returning 指定插入之后需要返回的列，cols 是字段名
+// 返回的结果会按照顺序写回 Values 传入的对象里面。
+// 只有支持 RETURNING 的方言才能使用，例如 PostgreSQL 和 SQLite
+func (i *Inserter[T]) Returning(cols ...string) *Inserter[T] {
+	i.returning = cols
+	return i
+}
+
+// BatchSize 指定每条语句最多插入多少行
+// Exec 的时候超过的部分会拆成多条语句，在同一个事务里面执行。
+// 不指定的话，按照方言允许的最大参数数量来拆分，Build 始终只构造一条语句
+func (i *Inserter[T]) BatchSize(n int) *Inserter[T] {
+	i.batchSize = n
+	return i
+}
+
+// Build 在副本上构造，不修改 Inserter 本身，所以可以重复调用，也可以并发调用
 func (i *Inserter[T]) Build() (*Query, error) {
+	cp := *i
+	cp.builder = i.builder.clone()
+	return cp.build()
+}
+
+func (i *Inserter[T]) build() (*Query, error) {
 	if len(i.values) == 0 {
 		return nil, errs.ErrInsertZeroRow
 	}
@@ -78,13 +128,58 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
-	i.sb.WriteString("INSERT INTO ")
-	i.quote(m.TableName)
-	i.sb.WriteString("(")
 
-	fields := m.Fields
+	fields, err := i.fields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	ins := insertValues{
+		table:  m.TableName,
+		fields: fields,
+		values: make([]valuer.Value, 0, len(i.values)),
+	}
+	for _, val := range i.values {
+		ins.values = append(ins.values, i.valCreator(val, m))
+	}
+
+	// (len(i.values) + 1) 中 +1 是考虑到 UPSERT 语句会传递额外的参数
+	i.args = make([]any, 0, len(fields)*(len(i.values)+1))
+	if i.upsert != nil {
+		err = i.dialect.buildUpsert(&i.builder, ins, i.upsert)
+	} else {
+		err = i.buildInsert(ins)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if returning := i.returningColumns(m); len(returning) > 0 {
+		if !i.dialect.supportReturning() {
+			return nil, errs.NewErrUnsupportedByDialect("RETURNING")
+		}
+		i.sb.WriteString(" RETURNING ")
+		for idx, c := range returning {
+			if idx > 0 {
+				i.sb.WriteByte(',')
+			}
+			if err = i.buildColumn(nil, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	i.sb.WriteString(";")
+	return &Query{
+		SQL:  i.sb.String(),
+		Args: i.args,
+	}, nil
+}
+
+// fields 返回要插入的列
+func (i *Inserter[T]) fields(m *model.Model) ([]*model.Field, error) {
 	if len(i.columns) != 0 {
-		fields = make([]*model.Field, 0, len(i.columns))
+		fields := make([]*model.Field, 0, len(i.columns))
 		for _, c := range i.columns {
 			field, ok := m.FieldMap[c]
 			if !ok {
@@ -92,55 +187,184 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			}
 			fields = append(fields, field)
 		}
+		return fields, nil
 	}
-
-	// (len(i.values) + 1) 中 +1 是考虑到 UPSERT 语句会传递额外的参数
-	i.args = make([]any, 0, len(fields)*(len(i.values)+1))
-	for idx, fd := range fields {
-		if idx > 0 {
-			i.sb.WriteByte(',')
+	if m.AutoIncrement == nil {
+		return m.Fields, nil
+	}
+	// 没有指定列的时候，自增列交给数据库生成
+	fields := make([]*model.Field, 0, len(m.Fields)-1)
+	for _, fd := range m.Fields {
+		if fd != m.AutoIncrement {
+			fields = append(fields, fd)
 		}
-		i.quote(fd.ColName)
 	}
+	return fields, nil
+}
 
-	i.sb.WriteString(") VALUES")
-	for vIdx, val := range i.values {
-		if vIdx > 0 {
-			i.sb.WriteByte(',')
+// rowsPerBatch 返回每条语句插入多少行，0 意味着不需要拆分
+// 指定了 BatchSize 也不能超过方言允许的参数数量，
+// UPSERT 里面的赋值也可能有参数，所以预留出来
+func (i *Inserter[T]) rowsPerBatch(m *model.Model) (int, error) {
+	size := i.batchSize
+	limit := i.dialect.maxParams()
+	if limit <= 0 {
+		return size, nil
+	}
+	fields, err := i.fields(m)
+	if err != nil {
+		return 0, err
+	}
+	if i.upsert != nil {
+		n, err := i.upsertArgs(m)
+		if err != nil {
+			return 0, err
 		}
-		refVal := i.valCreator(val, m)
-		i.sb.WriteByte('(')
-		for fIdx, field := range fields {
-			if fIdx > 0 {
-				i.sb.WriteByte(',')
+		limit -= n
+	}
+	rows := 1
+	if len(fields) > 0 && limit >= len(fields) {
+		rows = limit / len(fields)
+	}
+	if size <= 0 || size > rows {
+		size = rows
+	}
+	return size, nil
+}
+
+// upsertArgs 返回 UPSERT 的赋值部分绑定了多少个参数
+// 例如 Column 不需要参数，而 Raw("? + ?", a, b) 需要两个，所以直接构造一次来计算
+func (i *Inserter[T]) upsertArgs(m *model.Model) (int, error) {
+	b := i.builder.clone()
+	b.model = m
+	err := b.buildUpsertAssigns(i.upsert.assigns, func(colName string) {})
+	return len(b.args), err
+}
+
+// execBatches 把 Values 拆成多条语句，在同一个事务里面执行
+// 如果已经在事务里面，那么直接使用该事务，否则开启一个新的事务。
+// 每一批都在副本上执行，全部成功之后才把自增主键这些数据库生成的值写回，
+// 否则回滚之后对象上会残留不存在的行的主键
+func (i *Inserter[T]) execBatches(ctx context.Context, size int) Result {
+	var affected int64
+	copies := make([]*T, 0, len(i.values))
+	for _, val := range i.values {
+		cp := *val
+		copies = append(copies, &cp)
+	}
+	run := func(ctx context.Context, sess session) error {
+		for start := 0; start < len(copies); start += size {
+			end := start + size
+			if end > len(copies) {
+				end = len(copies)
 			}
-			i.sb.WriteByte('?')
-			fdVal, err := refVal.Field(field.GoName)
+			batch := NewInserter[T](sess)
+			batch.values = copies[start:end]
+			batch.columns = i.columns
+			batch.upsert = i.upsert
+			batch.returning = i.returning
+			batch.batchSize = size
+			res := batch.Exec(ctx)
+			n, err := res.RowsAffected()
 			if err != nil {
-				return nil, err
+				return err
 			}
-			i.addArgs(fdVal)
+			affected += n
 		}
-		i.sb.WriteByte(')')
+		return nil
 	}
 
-	if i.upsert != nil {
-		err = i.core.dialect.buildUpsert(&i.builder, i.upsert)
-		if err != nil {
-			return nil, err
+	var err error
+	if db, ok := i.sess.(*DB); ok {
+		err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			return run(ctx, tx)
+		}, nil)
+	} else {
+		err = run(ctx, i.sess)
+	}
+	if err != nil {
+		return Result{err: err}
+	}
+	for idx, cp := range copies {
+		*i.values[idx] = *cp
+	}
+	return Result{res: batchResult{affected: affected}}
+}
+
+// generatedField 返回由数据库生成的自增列
+// 如果通过 Columns 指定了自增列，那么它的值就是用户自己给的
+func (i *Inserter[T]) generatedField(m *model.Model) *model.Field {
+	if m.AutoIncrement == nil {
+		return nil
+	}
+	for _, c := range i.columns {
+		if c == m.AutoIncrement.GoName {
+			return nil
 		}
 	}
+	return m.AutoIncrement
+}
 
-	i.sb.WriteString(";")
-	return &Query{
-		SQL:  i.sb.String(),
-		Args: i.args,
-	}, nil
+// returningColumns 返回 RETURNING 的列
+// 用户没有指定的时候，如果方言没法通过 LastInsertId 回填自增主键，
+// 那么就 RETURNING 自增列
+func (i *Inserter[T]) returningColumns(m *model.Model) []string {
+	if len(i.returning) > 0 {
+		return i.returning
+	}
+	fd := i.generatedField(m)
+	if fd != nil && i.dialect.lastInsertIdMode() == lastInsertIdNone &&
+		i.dialect.supportReturning() {
+		return []string{fd.GoName}
+	}
+	return nil
 }
 
+// Exec 执行插入，并且把数据库生成的自增主键写回 Values 传入的对象
+// 行数超过 BatchSize 或者方言的参数限制的时候，会拆成多条语句在事务里面执行，
+// 返回的 Result 的 RowsAffected 是所有语句的总和
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
-	return exec(ctx, i.sess, i.core, &QueryContext{
+	m, err := i.r.Get(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	qc := &QueryContext{
 		Builder: i,
 		Type:    "INSERT",
-	})
+		Model:   m,
+	}
+	size, err := i.rowsPerBatch(m)
+	if err != nil {
+		return Result{err: err}
+	}
+	if size > 0 && len(i.values) > size {
+		return i.execBatches(ctx, size)
+	}
+	if len(i.returningColumns(m)) > 0 {
+		return execReturning[T](ctx, i.sess, i.core, qc, i.values)
+	}
+	res := exec(ctx, i.sess, i.core, qc)
+	// UPSERT 的时候部分行是更新，LastInsertId 没办法对应到每一行
+	if res.err != nil || i.upsert != nil {
+		return res
+	}
+	fd := i.generatedField(m)
+	mode := i.dialect.lastInsertIdMode()
+	if fd == nil || mode == lastInsertIdNone {
+		return res
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Result{err: err, res: res.res}
+	}
+	if mode == lastInsertIdLast {
+		id = id - int64(len(i.values)) + 1
+	}
+	for idx, val := range i.values {
+		err = i.valCreator(val, m).SetField(fd.GoName, id+int64(idx))
+		if err != nil {
+			return Result{err: err, res: res.res}
+		}
+	}
+	return res
 }
@@ -0,0 +1,435 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInserter_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type AutoIncrementModel struct {
+		Id   int64 `orm:"pk,auto_increment"`
+		Name string
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "no value",
+			q:       NewInserter[TestModel](db),
+			wantErr: errs.ErrInsertZeroRow,
+		},
+		{
+			name: "all columns",
+			q:    NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Tom", Age: 18}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?);",
+				Args: []any{int64(1), "Tom", int8(18), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// 指针接收器实现的 driver.Valuer 也要传给驱动
+			name: "pointer valuer",
+			q: NewInserter[ValuerModel](db).
+				Values(&ValuerModel{Id: 1, Tags: tagList{"a", "b"}}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `valuer_model`(`id`,`tags`) VALUES(?,?);",
+				Args: []any{int64(1), &tagList{"a", "b"}},
+			},
+		},
+		{
+			name: "partial columns",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Tom"}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) VALUES(?,?);",
+				Args: []any{int64(1), "Tom"},
+			},
+		},
+		{
+			name: "invalid column",
+			q: NewInserter[TestModel](db).Columns("Invalid").
+				Values(&TestModel{Id: 1}),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// 自增列交给数据库生成
+			name: "skip auto increment",
+			q: NewInserter[AutoIncrementModel](db).
+				Values(&AutoIncrementModel{Name: "Tom"}, &AutoIncrementModel{Name: "Jerry"}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `auto_increment_model`(`name`) VALUES(?),(?);",
+				Args: []any{"Tom", "Jerry"},
+			},
+		},
+		{
+			// 显式指定了就插入
+			name: "specify auto increment",
+			q: NewInserter[AutoIncrementModel](db).Columns("Id", "Name").
+				Values(&AutoIncrementModel{Id: 12, Name: "Tom"}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `auto_increment_model`(`id`,`name`) VALUES(?,?);",
+				Args: []any{int64(12), "Tom"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+// tagList 只有指针实现了 driver.Valuer
+type tagList []string
+
+func (t *tagList) Value() (driver.Value, error) {
+	return strings.Join(*t, ","), nil
+}
+
+type ValuerModel struct {
+	Id   int64
+	Tags tagList
+}
+
+func TestInserter_Exec(t *testing.T) {
+	type AutoIncrementModel struct {
+		Id   uint64 `orm:"pk,auto_increment"`
+		Name string
+	}
+
+	testCases := []struct {
+		name     string
+		dialect  Dialect
+		valuer   DBOption
+		mockFunc func(mock sqlmock.Sqlmock)
+		wantVals []*AutoIncrementModel
+		wantErr  error
+	}{
+		{
+			// MySQL 返回的是第一行的 ID
+			name:    "mysql",
+			dialect: MySQL,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+			},
+			wantVals: []*AutoIncrementModel{{Id: 11, Name: "Tom"}, {Id: 12, Name: "Jerry"}},
+		},
+		{
+			name:    "mysql reflect",
+			dialect: MySQL,
+			valuer:  DBUseReflectValuer(),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+			},
+			wantVals: []*AutoIncrementModel{{Id: 11, Name: "Tom"}, {Id: 12, Name: "Jerry"}},
+		},
+		{
+			// SQLite 返回的是最后一行的 ID
+			name:    "sqlite",
+			dialect: SQLite3,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(12, 2))
+			},
+			wantVals: []*AutoIncrementModel{{Id: 11, Name: "Tom"}, {Id: 12, Name: "Jerry"}},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow(int64(11)).AddRow(int64(12))
+				mock.ExpectQuery(`INSERT INTO "auto_increment_model"\("name"\) VALUES\(\$1\),\(\$2\) RETURNING "id";`).
+					WillReturnRows(rows)
+			},
+			wantVals: []*AutoIncrementModel{{Id: 11, Name: "Tom"}, {Id: 12, Name: "Jerry"}},
+		},
+		{
+			// 标准 SQL 没有办法回填
+			name:    "standard",
+			dialect: StandardSQL,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`INSERT INTO "auto_increment_model".*`).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			wantVals: []*AutoIncrementModel{{Name: "Tom"}, {Name: "Jerry"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			opts := []DBOption{DBWithDialect(tc.dialect)}
+			if tc.valuer != nil {
+				opts = append(opts, tc.valuer)
+			}
+			db, err := OpenDB(mockDB, opts...)
+			require.NoError(t, err)
+			tc.mockFunc(mock)
+
+			vals := []*AutoIncrementModel{{Name: "Tom"}, {Name: "Jerry"}}
+			res := NewInserter[AutoIncrementModel](db).Values(vals...).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			if res.Err() != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVals, vals)
+		})
+	}
+}
+
+func TestInserter_BatchExec(t *testing.T) {
+	type AutoIncrementModel struct {
+		Id   uint64 `orm:"pk,auto_increment"`
+		Name string
+	}
+
+	newVals := func(n int) []*AutoIncrementModel {
+		vals := make([]*AutoIncrementModel, 0, n)
+		for i := 0; i < n; i++ {
+			vals = append(vals, &AutoIncrementModel{Name: "Tom"})
+		}
+		return vals
+	}
+
+	testCases := []struct {
+		name         string
+		dialect      Dialect
+		batchSize    int
+		vals         []*AutoIncrementModel
+		mockFunc     func(mock sqlmock.Sqlmock)
+		wantAffected int64
+		wantIds      []uint64
+		wantErr      error
+	}{
+		{
+			name:      "batch size",
+			dialect:   MySQL,
+			batchSize: 2,
+			vals:      newVals(3),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?),(?);")).
+					WillReturnResult(sqlmock.NewResult(11, 2))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?);")).
+					WillReturnResult(sqlmock.NewResult(20, 1))
+				mock.ExpectCommit()
+			},
+			wantAffected: 3,
+			wantIds:      []uint64{11, 12, 20},
+		},
+		{
+			// 一批就能插入完的时候不需要事务
+			name:      "single batch",
+			dialect:   MySQL,
+			batchSize: 3,
+			vals:      newVals(3),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?),(?),(?);")).
+					WillReturnResult(sqlmock.NewResult(11, 3))
+			},
+			wantAffected: 3,
+			wantIds:      []uint64{11, 12, 13},
+		},
+		{
+			// SQLite 最多 999 个参数，每行一个参数
+			name:    "sqlite limit",
+			dialect: SQLite3,
+			vals:    newVals(1000),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(999, 999))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?);")).
+					WillReturnResult(sqlmock.NewResult(1000, 1))
+				mock.ExpectCommit()
+			},
+			wantAffected: 1000,
+		},
+		{
+			// 指定的 BatchSize 也不能超过方言的参数限制
+			name:      "batch size over limit",
+			dialect:   SQLite3,
+			batchSize: 5000,
+			vals:      newVals(1000),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(999, 999))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?);")).
+					WillReturnResult(sqlmock.NewResult(1000, 1))
+				mock.ExpectCommit()
+			},
+			wantAffected: 1000,
+		},
+		{
+			// 回滚之后，已经执行成功的批次也不能留下主键
+			name:      "rollback",
+			dialect:   MySQL,
+			batchSize: 2,
+			vals:      newVals(3),
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+				mock.ExpectExec("INSERT INTO `auto_increment_model`.*").
+					WillReturnError(errors.New("mock error"))
+				mock.ExpectRollback()
+			},
+			wantIds: []uint64{0, 0, 0},
+			wantErr: errors.New("mock error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, DBWithDialect(tc.dialect))
+			require.NoError(t, err)
+			tc.mockFunc(mock)
+
+			res := NewInserter[AutoIncrementModel](db).Values(tc.vals...).
+				BatchSize(tc.batchSize).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			require.NoError(t, mock.ExpectationsWereMet())
+			if res.Err() != nil {
+				ids := make([]uint64, 0, len(tc.vals))
+				for _, val := range tc.vals {
+					ids = append(ids, val.Id)
+				}
+				assert.Equal(t, tc.wantIds, ids)
+				return
+			}
+			affected, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAffected, affected)
+			if tc.wantIds == nil {
+				assert.Equal(t, uint64(1), tc.vals[0].Id)
+				assert.Equal(t, uint64(1000), tc.vals[999].Id)
+				return
+			}
+			ids := make([]uint64, 0, len(tc.vals))
+			for _, val := range tc.vals {
+				ids = append(ids, val.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+		})
+	}
+}
+
+// TestInserter_BatchExecUpsert UPSERT 里面的参数也要算进参数限制里面
+func TestInserter_BatchExecUpsert(t *testing.T) {
+	type AutoIncrementModel struct {
+		Id   uint64 `orm:"pk,auto_increment"`
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+
+	vals := make([]*AutoIncrementModel, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		vals = append(vals, &AutoIncrementModel{Name: "Tom"})
+	}
+	// 999 个参数，UPSERT 用掉两个，所以第一批只能插入 997 行
+	args := make([]driver.Value, 0, 999)
+	for i := 0; i < 997; i++ {
+		args = append(args, "Tom")
+	}
+	args = append(args, "a", "b")
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `auto_increment_model`.*").WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, 997))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auto_increment_model`(`name`) VALUES(?),(?),(?) ON CONFLICT(`name`) DO UPDATE SET `name`=? || ?;")).
+		WithArgs("Tom", "Tom", "Tom", "a", "b").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	res := NewInserter[AutoIncrementModel](db).Values(vals...).
+		OnDuplicateKey().ConflictColumns("Name").Update(Assign("Name", Raw("? || ?", "a", "b"))).
+		Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), affected)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_BatchExecInTx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// 已经在事务里面，直接使用这个事务
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `test_model`.*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `test_model`.*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	res := NewInserter[TestModel](tx).Values(&TestModel{Id: 1}, &TestModel{Id: 2}).
+		BatchSize(1).Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	require.NoError(t, tx.Commit())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_Clone(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	base := NewInserter[TestModel](db).Columns("Id", "FirstName").Values(&TestModel{Id: 1, FirstName: "Tom"})
+	q1, err := base.Build()
+	require.NoError(t, err)
+	q2, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "INSERT INTO `test_model`(`id`,`first_name`) VALUES(?,?);",
+		Args: []any{int64(1), "Tom"},
+	}, q2)
+	assert.Equal(t, q1, q2)
+
+	q, err := base.Clone().Values(&TestModel{Id: 2, FirstName: "Jerry"}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(2), "Jerry"}, q.Args)
+	q, err = base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q)
+}
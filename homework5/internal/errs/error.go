@@ -17,6 +17,16 @@ var (
 	// ErrInsertZeroRow 代表插入 0 行
 	ErrInsertZeroRow = errors.New("orm: 插入 0 行")
 	ErrNoUpdatedColumns = errors.New("orm: 未指定更新的列")
+	// ErrNoPrimaryKey 代表按照主键更新的时候，模型没有主键
+	// 这种情况下必须通过 Where 指定条件，否则会更新整张表
+	ErrNoPrimaryKey = errors.New("orm: 模型没有主键，必须指定 WHERE 条件")
+	// ErrEmptyCaseWhen 代表 CASE 表达式没有任何 WHEN 分支
+	ErrEmptyCaseWhen = errors.New("orm: CASE 至少需要一个 WHEN 分支")
+	// ErrMultipleAutoIncrement 一个模型只能有一个自增列
+	ErrMultipleAutoIncrement = errors.New("orm: 只能有一个自增列")
+	// ErrUnsupportedByDialect 代表当前方言无法表达该语法
+	// 具体的语法通过 NewErrUnsupportedByDialect 附加在错误信息里面
+	ErrUnsupportedByDialect = errors.New("orm: 当前方言不支持")
 )
 
 // NewErrUnknownField 返回代表未知字段的错误
@@ -63,6 +73,46 @@ func NewErrUnsupportedSelectable(exp any) error {
 // 发生该错误，主要是因为传入了不支持的 Expression 的实际类型
 // 一般来说，这是因为中间件
 
+// NewErrUnsupportedByDialect 返回当前方言不支持 feature 的错误
+// 可以用 errors.Is(err, ErrUnsupportedByDialect) 来判断
+func NewErrUnsupportedByDialect(feature string) error {
+	return fmt.Errorf("%w %s", ErrUnsupportedByDialect, feature)
+}
+
+// NewErrIncompatibleValue 返回值无法赋给字段的错误
+func NewErrIncompatibleValue(fd string, val any) error {
+	return fmt.Errorf("orm: 值 %v 无法赋给字段 %s", val, fd)
+}
+
+// NewErrInvalidAutoIncrement 返回自增列类型错误
+// 数据库生成的自增主键会被写回字段，所以字段必须是整数
+func NewErrInvalidAutoIncrement(fd string, typ any) error {
+	return fmt.Errorf("orm: 自增列 %s 必须是整数类型，而不是 %v", fd, typ)
+}
+
+// NewErrUnsupportedEmbedded 返回不支持嵌入该字段的错误
+// 嵌入的结构体指针没有办法通过偏移量访问
+func NewErrUnsupportedEmbedded(fd string) error {
+	return fmt.Errorf("orm: 不支持嵌入结构体指针 %s", fd)
+}
+
+// NewErrAmbiguousField 返回字段有歧义的错误
+// 多个同一层级的嵌入结构体里面有同名字段，Go 也没办法直接访问这个字段
+func NewErrAmbiguousField(fd string) error {
+	return fmt.Errorf("orm: 字段 %s 有歧义，多个嵌入结构体里面有同名字段", fd)
+}
+
+// NewErrUndefinedCTE 返回 CTE 没有定义查询的错误
+// CTEOf 只能用来引用，不能放在 WITH 里面
+func NewErrUndefinedCTE(name string) error {
+	return fmt.Errorf("orm: CTE %s 没有定义查询", name)
+}
+
+// NewErrInvalidJSONPath 返回 JSON 路径格式错误
+func NewErrInvalidJSONPath(path string) error {
+	return fmt.Errorf("orm: 错误的 JSON 路径 %s，必须以 $ 开头", path)
+}
+
 func NewErrInvalidTagContent(tag string) error {
 	return fmt.Errorf("orm: 错误的标签设置: %s", tag)
 }
@@ -30,7 +30,15 @@ func (r reflectValue) Field(name string) (any, error) {
 	if res == (reflect.Value{}) {
 		return nil, errs.NewErrUnknownField(name)
 	}
-	return res.Interface(), nil
+	return fieldValue(res, r.meta.FieldMap[name])
+}
+
+func (r reflectValue) SetField(name string, val any) error {
+	fd := r.val.FieldByName(name)
+	if fd == (reflect.Value{}) {
+		return errs.NewErrUnknownField(name)
+	}
+	return setValue(name, fd, val)
 }
 
 func (r reflectValue) SetColumns(rows *sql.Rows) error {
@@ -42,25 +50,24 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		return errs.ErrTooManyReturnedColumns
 	}
 
-	// colValues 和 colEleValues 实质上最终都指向同一个对象
 	colValues := make([]interface{}, len(cs))
-	colEleValues := make([]reflect.Value, len(cs))
+	colFields := make([]reflect.Value, len(cs))
 	for i, c := range cs {
 		cm, ok := r.meta.ColumnMap[c]
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
-		val := reflect.New(cm.Type)
-		colValues[i] = val.Interface()
-		colEleValues[i] = val.Elem()
+		colFields[i] = r.val.FieldByName(cm.GoName)
+		colValues[i] = scanDest(colFields[i], cm)
 	}
 	if err = rows.Scan(colValues...); err != nil {
 		return err
 	}
 	for i, c := range cs {
-		cm := r.meta.ColumnMap[c]
-		fd := r.val.FieldByName(cm.GoName)
-		fd.Set(colEleValues[i])
+		err = setScanned(colFields[i], r.meta.ColumnMap[c], colValues[i])
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
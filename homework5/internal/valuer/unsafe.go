@@ -41,9 +41,21 @@ func (u unsafeValue) Field(name string) (interface{}, error) {
 	if !ok {
 		return nil, errs.NewErrUnknownField(name)
 	}
+	return fieldValue(u.fieldAt(fd), fd)
+}
+
+func (u unsafeValue) SetField(name string, val any) error {
+	fd, ok := u.meta.FieldMap[name]
+	if !ok {
+		return errs.NewErrUnknownField(name)
+	}
+	return setValue(name, u.fieldAt(fd), val)
+}
+
+// fieldAt 返回字段对应的 reflect.Value，它是可寻址的
+func (u unsafeValue) fieldAt(fd *model.Field) reflect.Value {
 	ptr := unsafe.Pointer(uintptr(u.addr) + fd.Offset)
-	val := reflect.NewAt(fd.Type, ptr).Elem()
-	return val.Interface(), nil
+	return reflect.NewAt(fd.Type, ptr).Elem()
 }
 
 func (u unsafeValue) SetColumns(rows *sql.Rows) error {
@@ -56,14 +68,23 @@ func (u unsafeValue) SetColumns(rows *sql.Rows) error {
 	}
 
 	colValues := make([]interface{}, len(cs))
+	colFields := make([]reflect.Value, len(cs))
 	for i, c := range cs {
 		cm, ok := u.meta.ColumnMap[c]
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
-		ptr := unsafe.Pointer(uintptr(u.addr) + cm.Offset)
-		val := reflect.NewAt(cm.Type, ptr)
-		colValues[i] = val.Interface()
+		colFields[i] = u.fieldAt(cm)
+		colValues[i] = scanDest(colFields[i], cm)
+	}
+	if err = rows.Scan(colValues...); err != nil {
+		return err
+	}
+	for i, c := range cs {
+		err = setScanned(colFields[i], u.meta.ColumnMap[c], colValues[i])
+		if err != nil {
+			return err
+		}
 	}
-	return rows.Scan(colValues...)
+	return nil
 }
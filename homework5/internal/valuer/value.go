@@ -16,7 +16,11 @@ package valuer
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"exercise/geektime/homework5/version1/internal/errs"
 	"exercise/geektime/homework5/version1/model"
+	"reflect"
 )
 
 // Value 是对结构体实例的内部抽象
@@ -25,10 +29,114 @@ type Value interface {
 	Field(name string) (any, error)
 	// SetColumns 设置新值
 	SetColumns(rows *sql.Rows) error
+	// SetField 设置字段的值，val 会被转换为字段的类型
+	// 例如把 LastInsertId 返回的 int64 写入 uint64 类型的主键
+	SetField(name string, val any) error
 }
 
 type Creator func(val interface{}, meta *model.Model) Value
 
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// fieldValue 返回字段的值，用于作为查询参数
+// JSON 列返回编码之后的字符串。
+// 如果是指针接收器实现了 driver.Valuer，那么返回字段的地址，
+// 否则 database/sql 识别不出来
+func fieldValue(fd reflect.Value, meta *model.Field) (any, error) {
+	if meta != nil && meta.JSON {
+		if fd.Kind() == reflect.Pointer && fd.IsNil() {
+			return nil, nil
+		}
+		bs, err := json.Marshal(fd.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(bs), nil
+	}
+	typ := fd.Type()
+	if fd.CanAddr() && !typ.Implements(valuerType) &&
+		reflect.PointerTo(typ).Implements(valuerType) {
+		return fd.Addr().Interface(), nil
+	}
+	return fd.Interface(), nil
+}
+
+// scanDest 返回 rows.Scan 的目标，fd 必须是可寻址的
+// 大多数情况下直接扫描到字段里面，NULL 交给 database/sql 处理：
+// 指针字段会被设置为 nil，sql.Scanner 自己处理 NULL，其余类型返回错误。
+// 设置了 NullAsZero 的字段先扫描到一个指针里面，JSON 列先扫描到 []byte 里面，
+// 再通过 setScanned 写回字段
+func scanDest(fd reflect.Value, meta *model.Field) any {
+	if meta.JSON {
+		return new([]byte)
+	}
+	if needHolder(meta) {
+		return reflect.New(reflect.PointerTo(meta.Type)).Interface()
+	}
+	return fd.Addr().Interface()
+}
+
+// setScanned 把 scanDest 扫描到的结果写回字段
+// JSON 列读到 NULL 的时候设置为零值
+func setScanned(fd reflect.Value, meta *model.Field, dest any) error {
+	if meta.JSON {
+		bs := *(dest.(*[]byte))
+		if bs == nil {
+			fd.Set(reflect.Zero(meta.Type))
+			return nil
+		}
+		return json.Unmarshal(bs, fd.Addr().Interface())
+	}
+	if !needHolder(meta) {
+		return nil
+	}
+	holder := reflect.ValueOf(dest).Elem()
+	if holder.IsNil() {
+		fd.Set(reflect.Zero(meta.Type))
+		return nil
+	}
+	fd.Set(holder.Elem())
+	return nil
+}
+
+func needHolder(meta *model.Field) bool {
+	return meta.NullAsZero && meta.Type.Kind() != reflect.Pointer &&
+		!reflect.PointerTo(meta.Type).Implements(scannerType)
+}
+
+// setValue 把 val 赋给 fd，只在数字类型之间进行转换
+// 其它的转换虽然 reflect 允许，但是没有意义，例如 int64 转 string 得到的是一个字符
+func setValue(name string, fd reflect.Value, val any) error {
+	v := reflect.ValueOf(val)
+	if !v.IsValid() {
+		fd.Set(reflect.Zero(fd.Type()))
+		return nil
+	}
+	if v.Type().AssignableTo(fd.Type()) {
+		fd.Set(v)
+		return nil
+	}
+	if !isNumber(v.Kind()) || !isNumber(fd.Kind()) {
+		return errs.NewErrIncompatibleValue(name, val)
+	}
+	fd.Set(v.Convert(fd.Type()))
+	return nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 // ResultSetHandler 这是另外一种可行的设计方案
 // type ResultSetHandler interface {
 // 	// SetColumns 设置新值，column 是列名
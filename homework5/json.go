@@ -0,0 +1,118 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JsonColumn 代表存储 JSON 的列
+// Valid 为 false 的时候写入 NULL，读到 NULL 的时候 Valid 也为 false
+// 如果不想使用这个类型，也可以直接在字段上使用 orm:"json" 标签
+type JsonColumn[T any] struct {
+	Val   T
+	Valid bool
+}
+
+func (j JsonColumn[T]) Value() (driver.Value, error) {
+	if !j.Valid {
+		return nil, nil
+	}
+	bs, err := json.Marshal(j.Val)
+	if err != nil {
+		return nil, err
+	}
+	return string(bs), nil
+}
+
+func (j *JsonColumn[T]) Scan(src any) error {
+	var bs []byte
+	switch val := src.(type) {
+	case nil:
+		var zero T
+		j.Val, j.Valid = zero, false
+		return nil
+	case []byte:
+		bs = val
+	case string:
+		bs = []byte(val)
+	default:
+		return fmt.Errorf("orm: JsonColumn.Scan 不支持 src 类型 %T", src)
+	}
+	if err := json.Unmarshal(bs, &j.Val); err != nil {
+		return err
+	}
+	j.Valid = true
+	return nil
+}
+
+// JSONPathExpr 代表从 JSON 列里面按照路径取值
+// 具体的 SQL 由方言决定
+type JSONPathExpr struct {
+	col  Column
+	path string
+}
+
+func (JSONPathExpr) expr() {}
+
+// JSONPath 按照路径取 JSON 列里面的值，取出来的值是文本
+// path 使用 MySQL 和 SQLite 的写法，例如 $.color，$.tags[0]
+func (c Column) JSONPath(path string) JSONPathExpr {
+	return JSONPathExpr{
+		col:  c,
+		path: path,
+	}
+}
+
+func (j JSONPathExpr) EQ(arg any) Predicate {
+	return newPredicate(j, opEQ, arg)
+}
+
+func (j JSONPathExpr) NEQ(arg any) Predicate {
+	return newPredicate(j, opNEQ, arg)
+}
+
+func (j JSONPathExpr) LT(arg any) Predicate {
+	return newPredicate(j, opLT, arg)
+}
+
+func (j JSONPathExpr) LTEQ(arg any) Predicate {
+	return newPredicate(j, opLTEQ, arg)
+}
+
+func (j JSONPathExpr) GT(arg any) Predicate {
+	return newPredicate(j, opGT, arg)
+}
+
+func (j JSONPathExpr) GTEQ(arg any) Predicate {
+	return newPredicate(j, opGTEQ, arg)
+}
+
+func (j JSONPathExpr) In(vals ...any) Predicate {
+	return inPredicate(j, opIN, vals)
+}
+
+func (j JSONPathExpr) NotIn(vals ...any) Predicate {
+	return inPredicate(j, opNotIN, vals)
+}
+
+func (j JSONPathExpr) Like(pattern any) Predicate {
+	return newPredicate(j, opLike, pattern)
+}
+
+func (j JSONPathExpr) NotLike(pattern any) Predicate {
+	return newPredicate(j, opNotLike, pattern)
+}
+
+func (j JSONPathExpr) Between(lo, hi any) Predicate {
+	return betweenPredicate(j, lo, hi)
+}
+
+// IsNull 路径不存在的时候也是 NULL
+func (j JSONPathExpr) IsNull() Predicate {
+	return nullPredicate(j, opIsNull)
+}
+
+func (j JSONPathExpr) IsNotNull() Predicate {
+	return nullPredicate(j, opIsNotNull)
+}
@@ -0,0 +1,229 @@
+package orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestJsonColumn_Value(t *testing.T) {
+	testCases := []struct {
+		name    string
+		col     JsonColumn[map[string]string]
+		wantVal driver.Value
+	}{
+		{
+			name:    "invalid",
+			col:     JsonColumn[map[string]string]{Val: map[string]string{"a": "b"}},
+			wantVal: nil,
+		},
+		{
+			name:    "valid",
+			col:     JsonColumn[map[string]string]{Val: map[string]string{"a": "b"}, Valid: true},
+			wantVal: `{"a":"b"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := tc.col.Value()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestJsonColumn_Scan(t *testing.T) {
+	testCases := []struct {
+		name    string
+		src     any
+		wantCol JsonColumn[[]int]
+		wantErr bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:    "bytes",
+			src:     []byte("[1,2]"),
+			wantCol: JsonColumn[[]int]{Val: []int{1, 2}, Valid: true},
+		},
+		{
+			name:    "string",
+			src:     "[3]",
+			wantCol: JsonColumn[[]int]{Val: []int{3}, Valid: true},
+		},
+		{
+			name:    "invalid json",
+			src:     "[3",
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			src:     12,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var col JsonColumn[[]int]
+			err := col.Scan(tc.src)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCol, col)
+		})
+	}
+}
+
+func TestJSON_ReadWrite(t *testing.T) {
+	type Attrs struct {
+		Color string `json:"color"`
+	}
+	type Product struct {
+		Id    int64
+		Attrs Attrs `orm:"json"`
+		Tags  JsonColumn[[]string]
+	}
+
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflectValuer()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, tc.opts...)
+			require.NoError(t, err)
+
+			// 写入的时候编码
+			q, err := NewInserter[Product](db).Values(&Product{
+				Id:    1,
+				Attrs: Attrs{Color: "red"},
+				Tags:  JsonColumn[[]string]{Val: []string{"a"}, Valid: true},
+			}).Build()
+			require.NoError(t, err)
+			assert.Equal(t, []any{int64(1), `{"color":"red"}`,
+				JsonColumn[[]string]{Val: []string{"a"}, Valid: true}}, q.Args)
+
+			q, err = NewUpdater[Product](db).Update(&Product{Attrs: Attrs{Color: "blue"}}).
+				Set(C("Attrs")).Where(C("Id").EQ(1)).Build()
+			require.NoError(t, err)
+			assert.Equal(t, []any{`{"color":"blue"}`, 1}, q.Args)
+
+			q, err = NewInserter[Product](db).Values(&Product{Id: 1}).
+				OnDuplicateKey().Update(Assign("Attrs", Attrs{Color: "green"})).Build()
+			require.NoError(t, err)
+			assert.Equal(t, `{"color":"green"}`, q.Args[len(q.Args)-1])
+
+			q, err = NewSelector[Product](db).Where(C("Attrs").In(Attrs{Color: "red"}, Attrs{Color: "blue"})).Build()
+			require.NoError(t, err)
+			assert.Equal(t, []any{`{"color":"red"}`, `{"color":"blue"}`}, q.Args)
+
+			// LIKE 的模式原样传递
+			q, err = NewSelector[Product](db).Where(C("Attrs").Like("%red%")).Build()
+			require.NoError(t, err)
+			assert.Equal(t, []any{"%red%"}, q.Args)
+
+			// 读取的时候解码，NULL 对应零值
+			rows := sqlmock.NewRows([]string{"id", "attrs", "tags"})
+			rows.AddRow([]byte("1"), []byte(`{"color":"red"}`), []byte(`["a","b"]`))
+			rows.AddRow([]byte("2"), nil, nil)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `product`;")).WillReturnRows(rows)
+			res, err := NewSelector[Product](db).GetMulti(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []*Product{
+				{
+					Id:    1,
+					Attrs: Attrs{Color: "red"},
+					Tags:  JsonColumn[[]string]{Val: []string{"a", "b"}, Valid: true},
+				},
+				{Id: 2},
+			}, res)
+		})
+	}
+}
+
+func TestJSONPath_Predicates(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type Product struct {
+		Id    int64
+		Attrs map[string]any `orm:"json"`
+	}
+	color := C("Attrs").JSONPath("$.color")
+
+	testCases := []struct {
+		name      string
+		where     []Predicate
+		wantQuery *Query
+	}{
+		{
+			name:  "neq",
+			where: []Predicate{color.NEQ("red")},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) != ?;",
+				Args: []any{"$.color", "red"},
+			},
+		},
+		{
+			name:  "in",
+			where: []Predicate{color.In("red", "blue")},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) IN (?,?);",
+				Args: []any{"$.color", "red", "blue"},
+			},
+		},
+		{
+			name:  "not like",
+			where: []Predicate{color.NotLike("gr%")},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) NOT LIKE ?;",
+				Args: []any{"$.color", "gr%"},
+			},
+		},
+		{
+			name:  "between",
+			where: []Predicate{C("Attrs").JSONPath("$.size").Between(1, 3)},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) BETWEEN ? AND ?;",
+				Args: []any{"$.size", 1, 3},
+			},
+		},
+		{
+			name:  "is null",
+			where: []Predicate{color.IsNull()},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_UNQUOTE(JSON_EXTRACT(`attrs`,?)) IS NULL;",
+				Args: []any{"$.color"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewSelector[Product](db).Where(tc.where...).Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Recorder = &MemoryRecorder{}
+
+// Key 是一组指标的标签
+type Key struct {
+	Type  string
+	Table string
+}
+
+// MemoryRecorder 把指标保存在内存里面，主要用于测试
+type MemoryRecorder struct {
+	mu        sync.RWMutex
+	latencies map[Key][]time.Duration
+	errors    map[Key]int
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{
+		latencies: map[Key][]time.Duration{},
+		errors:    map[Key]int{},
+	}
+}
+
+func (m *MemoryRecorder) ObserveLatency(typ, table string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := Key{Type: typ, Table: table}
+	m.latencies[key] = append(m.latencies[key], d)
+}
+
+func (m *MemoryRecorder) IncError(typ, table string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[Key{Type: typ, Table: table}]++
+}
+
+// Latencies 返回记录下来的耗时，返回的切片是一个副本
+func (m *MemoryRecorder) Latencies(typ, table string) []time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]time.Duration(nil), m.latencies[Key{Type: typ, Table: table}]...)
+}
+
+// Errors 返回记录下来的错误次数
+func (m *MemoryRecorder) Errors(typ, table string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.errors[Key{Type: typ, Table: table}]
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"time"
+)
+
+// Recorder 记录查询的指标，可以对接不同的监控系统
+// typ 是 QueryContext.Type，table 是表名，原生查询没有元数据的时候表名是空字符串
+type Recorder interface {
+	// ObserveLatency 记录一次查询的耗时，不管成功还是失败
+	ObserveLatency(typ, table string, d time.Duration)
+	// IncError 记录一次查询失败
+	IncError(typ, table string)
+}
+
+// MiddlewareBuilder 构造按照查询类型和表统计耗时和错误的 Middleware
+type MiddlewareBuilder struct {
+	recorder Recorder
+	isError  func(err error) bool
+}
+
+func NewMiddlewareBuilder(recorder Recorder) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		recorder: recorder,
+		isError: func(err error) bool {
+			return !errors.Is(err, orm.ErrNoRows)
+		},
+	}
+}
+
+// IsError 判断一个错误是不是要记录为查询失败
+// 默认情况下 ErrNoRows 是正常的业务结果，不计入错误
+func (m *MiddlewareBuilder) IsError(fn func(err error) bool) *MiddlewareBuilder {
+	m.isError = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() orm.Middleware {
+	return func(next orm.HandleFunc) orm.HandleFunc {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			start := time.Now()
+			res := next(ctx, qc)
+			table := ""
+			if qc.Model != nil {
+				table = qc.Model.TableName
+			}
+			m.recorder.ObserveLatency(qc.Type, table, time.Since(start))
+			if res.Err != nil && m.isError(res.Err) {
+				m.recorder.IncError(qc.Type, table)
+			}
+			return res
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	recorder := NewMemoryRecorder()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(NewMiddlewareBuilder(recorder).Build()))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnError(errors.New("exec error"))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	_, err = orm.NewSelector[TestModel](db).Get(ctx)
+	require.NoError(t, err)
+	_, err = orm.NewSelector[TestModel](db).Get(ctx)
+	assert.Equal(t, orm.ErrNoRows, err)
+	require.NoError(t, orm.NewDeleter[TestModel](db).Exec(ctx).Err())
+	assert.Error(t, orm.NewDeleter[TestModel](db).Exec(ctx).Err())
+	require.NoError(t, orm.RawQuery[TestModel](db, "UPDATE `test_model` SET `age` = 1").Exec(ctx).Err())
+
+	assert.Len(t, recorder.Latencies("SELECT", "test_model"), 2)
+	assert.Equal(t, 0, recorder.Errors("SELECT", "test_model"))
+	assert.Len(t, recorder.Latencies("DELETE", "test_model"), 2)
+	assert.Equal(t, 1, recorder.Errors("DELETE", "test_model"))
+	assert.Len(t, recorder.Latencies("RAW", "test_model"), 1)
+	assert.Len(t, recorder.Latencies("INSERT", "test_model"), 0)
+}
+
+func TestMiddlewareBuilder_IsError(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	recorder := NewMemoryRecorder()
+	mdl := NewMiddlewareBuilder(recorder).IsError(func(err error) bool {
+		return true
+	}).Build()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(mdl))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = orm.NewSelector[TestModel](db).Get(context.Background())
+	assert.Equal(t, orm.ErrNoRows, err)
+	assert.Equal(t, 1, recorder.Errors("SELECT", "test_model"))
+}
+
+type TestModel struct {
+	Id        int64
+	FirstName string
+	Age       int8
+}
@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"exercise/geektime/homework5/version1/middleware/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+var (
+	_ metrics.Recorder     = &Recorder{}
+	_ prometheus.Collector = &Recorder{}
+)
+
+// Recorder 把指标记录到 Prometheus 的 HistogramVec 和 CounterVec 里面
+// 它本身是一个 prometheus.Collector，需要注册到 prometheus.Registerer 上才会暴露出去
+type Recorder struct {
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+// RecorderOption 用于调整指标的命名和桶
+type RecorderOption func(opts *recorderOpts)
+
+type recorderOpts struct {
+	namespace string
+	subsystem string
+	buckets   []float64
+}
+
+func WithNamespace(namespace string) RecorderOption {
+	return func(opts *recorderOpts) {
+		opts.namespace = namespace
+	}
+}
+
+func WithSubsystem(subsystem string) RecorderOption {
+	return func(opts *recorderOpts) {
+		opts.subsystem = subsystem
+	}
+}
+
+// WithBuckets 设置耗时直方图的桶，单位是秒
+func WithBuckets(buckets []float64) RecorderOption {
+	return func(opts *recorderOpts) {
+		opts.buckets = buckets
+	}
+}
+
+// NewRecorder 创建两个指标：
+// orm_query_duration_seconds 是耗时直方图，orm_query_errors_total 是错误计数，
+// 两者都带有 type 和 table 两个标签
+func NewRecorder(opts ...RecorderOption) *Recorder {
+	o := &recorderOpts{
+		namespace: "orm",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	labels := []string{"type", "table"}
+	return &Recorder{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "query_duration_seconds",
+			Help:      "Latency of ORM queries in seconds.",
+			Buckets:   o.buckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "query_errors_total",
+			Help:      "Number of failed ORM queries.",
+		}, labels),
+	}
+}
+
+func (r *Recorder) ObserveLatency(typ, table string, d time.Duration) {
+	r.latency.WithLabelValues(typ, table).Observe(d.Seconds())
+}
+
+func (r *Recorder) IncError(typ, table string) {
+	r.errors.WithLabelValues(typ, table).Inc()
+}
+
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	r.latency.Describe(ch)
+	r.errors.Describe(ch)
+}
+
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	r.latency.Collect(ch)
+	r.errors.Collect(ch)
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := NewRecorder(WithSubsystem("test"), WithBuckets([]float64{0.01, 0.1}))
+	require.NoError(t, reg.Register(r))
+
+	r.ObserveLatency("SELECT", "user", 5*time.Millisecond)
+	r.ObserveLatency("SELECT", "user", 50*time.Millisecond)
+	r.ObserveLatency("DELETE", "order", time.Second)
+	r.IncError("DELETE", "order")
+
+	assert.Equal(t, 2, testutil.CollectAndCount(r, "orm_test_query_duration_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.errors.WithLabelValues("DELETE", "order")))
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP orm_test_query_duration_seconds Latency of ORM queries in seconds.
+# TYPE orm_test_query_duration_seconds histogram
+orm_test_query_duration_seconds_bucket{table="order",type="DELETE",le="0.01"} 0
+orm_test_query_duration_seconds_bucket{table="order",type="DELETE",le="0.1"} 0
+orm_test_query_duration_seconds_bucket{table="order",type="DELETE",le="+Inf"} 1
+orm_test_query_duration_seconds_sum{table="order",type="DELETE"} 1
+orm_test_query_duration_seconds_count{table="order",type="DELETE"} 1
+orm_test_query_duration_seconds_bucket{table="user",type="SELECT",le="0.01"} 1
+orm_test_query_duration_seconds_bucket{table="user",type="SELECT",le="0.1"} 2
+orm_test_query_duration_seconds_bucket{table="user",type="SELECT",le="+Inf"} 2
+orm_test_query_duration_seconds_sum{table="user",type="SELECT"} 0.055
+orm_test_query_duration_seconds_count{table="user",type="SELECT"} 2
+# HELP orm_test_query_errors_total Number of failed ORM queries.
+# TYPE orm_test_query_errors_total counter
+orm_test_query_errors_total{table="order",type="DELETE"} 1
+`), "orm_test_query_duration_seconds", "orm_test_query_errors_total")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,124 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"unicode/utf8"
+)
+
+const instrumentationName = "exercise/geektime/homework5/version1/middleware/opentelemetry"
+
+// MiddlewareBuilder 构造链路追踪的 Middleware
+// 每一个查询都会创建一个 span，父 span 来自于传入的 context
+type MiddlewareBuilder struct {
+	tracer       trace.Tracer
+	system       string
+	maxStatement int
+	sample       func(ctx context.Context, qc *orm.QueryContext) bool
+	isError      func(err error) bool
+}
+
+// NewMiddlewareBuilder 默认使用全局的 TracerProvider
+// db.system 默认是 other_sql，可以通过 System 指定
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		system: "other_sql",
+		isError: func(err error) bool {
+			return !errors.Is(err, orm.ErrNoRows)
+		},
+	}
+}
+
+func (m *MiddlewareBuilder) Tracer(tracer trace.Tracer) *MiddlewareBuilder {
+	m.tracer = tracer
+	return m
+}
+
+// System 设置 db.system，例如 mysql、sqlite、postgresql
+func (m *MiddlewareBuilder) System(system string) *MiddlewareBuilder {
+	m.system = system
+	return m
+}
+
+// MaxStatementLength 限制 db.statement 的长度，超过的部分会被截断
+// 0 代表不截断，负数代表不记录语句
+func (m *MiddlewareBuilder) MaxStatementLength(n int) *MiddlewareBuilder {
+	m.maxStatement = n
+	return m
+}
+
+// Sample 决定一个查询要不要创建 span，返回 false 的时候直接执行查询
+// 它和 TracerProvider 的 Sampler 是叠加的关系
+func (m *MiddlewareBuilder) Sample(fn func(ctx context.Context, qc *orm.QueryContext) bool) *MiddlewareBuilder {
+	m.sample = fn
+	return m
+}
+
+// IsError 判断一个错误是不是要把 span 标记为失败
+// 默认情况下 ErrNoRows 是正常的业务结果，span 的状态保持 Unset
+func (m *MiddlewareBuilder) IsError(fn func(err error) bool) *MiddlewareBuilder {
+	m.isError = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() orm.Middleware {
+	tracer := m.tracer
+	if tracer == nil {
+		tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return func(next orm.HandleFunc) orm.HandleFunc {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			if m.sample != nil && !m.sample(ctx, qc) {
+				return next(ctx, qc)
+			}
+			table := ""
+			if qc.Model != nil {
+				table = qc.Model.TableName
+			}
+			spanName := qc.Type
+			if table != "" {
+				spanName = spanName + " " + table
+			}
+			attrs := []attribute.KeyValue{
+				attribute.String("db.system", m.system),
+				attribute.String("db.operation", qc.Type),
+			}
+			if table != "" {
+				attrs = append(attrs, attribute.String("db.sql.table", table))
+			}
+			ctx, span := tracer.Start(ctx, spanName,
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			defer span.End()
+			// 没有被采样的 span 不会记录属性，也就没必要多构造一次语句
+			if m.maxStatement >= 0 && span.IsRecording() {
+				// Build 可以重复调用，这里拿到的就是执行的语句
+				if q, err := qc.Builder.Build(); err == nil {
+					span.SetAttributes(attribute.String("db.statement", m.truncate(q.SQL)))
+				}
+			}
+			res := next(ctx, qc)
+			if res.Err != nil && m.isError(res.Err) {
+				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+			}
+			return res
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) truncate(stmt string) string {
+	if m.maxStatement == 0 || len(stmt) <= m.maxStatement {
+		return stmt
+	}
+	// 不要把一个多字节的字符截成两半
+	n := m.maxStatement
+	for n > 0 && !utf8.RuneStart(stmt[n]) {
+		n--
+	}
+	return stmt[:n]
+}
@@ -0,0 +1,160 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+type User struct {
+	Id        int64
+	FirstName string
+	Age       int8
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	m := NewMiddlewareBuilder().Tracer(tracer).System("mysql").Build()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(m))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE .*").WillReturnError(errors.New("exec error"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, err = orm.NewSelector[User](db).Where(orm.C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Error(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+	_, err = orm.NewSelector[User](db).Get(ctx)
+	assert.Equal(t, orm.ErrNoRows, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+
+	span := spans[0]
+	assert.Equal(t, "SELECT user", span.Name)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+	// 父 span 来自于传入的 context
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("db.system", "mysql"),
+		attribute.String("db.operation", "SELECT"),
+		attribute.String("db.sql.table", "user"),
+		attribute.String("db.statement", "SELECT * FROM `user` WHERE `id` = ?;"),
+	}, span.Attributes)
+
+	span = spans[1]
+	assert.Equal(t, "DELETE user", span.Name)
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "exec error", span.Status.Description)
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "exception", span.Events[0].Name)
+
+	// ErrNoRows 是正常的业务结果
+	span = spans[2]
+	assert.Equal(t, "SELECT user", span.Name)
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	assert.Len(t, span.Events, 0)
+}
+
+func TestMiddlewareBuilder_NotRecording(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test")
+	m := NewMiddlewareBuilder().Tracer(tracer).Build()
+	qb := &countBuilder{}
+	res := m(func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+		return &orm.QueryResult{}
+	})(context.Background(), &orm.QueryContext{Type: "SELECT", Builder: qb})
+	assert.NoError(t, res.Err)
+	// 没有被采样的时候不需要构造语句
+	assert.Equal(t, 0, qb.cnt)
+}
+
+type countBuilder struct {
+	cnt int
+}
+
+func (b *countBuilder) Build() (*orm.Query, error) {
+	b.cnt++
+	return &orm.Query{SQL: "SELECT 1;"}, nil
+}
+
+func TestMiddlewareBuilder_MaxStatementLength(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(
+		NewMiddlewareBuilder().Tracer(tracer).MaxStatementLength(11).Build()))
+	require.NoError(t, err)
+	require.NoError(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+
+	// 截断的位置落在多字节字符中间的时候，往前退到字符的边界
+	db, err = orm.OpenDB(mockDB, orm.DBWithMiddleware(
+		NewMiddlewareBuilder().Tracer(tracer).MaxStatementLength(9).Build()))
+	require.NoError(t, err)
+	require.NoError(t, orm.RawQuery[int](db, "UPDATE 用户 SET age = 1").Exec(ctx).Err())
+
+	// 负数代表不记录语句
+	db, err = orm.OpenDB(mockDB, orm.DBWithMiddleware(
+		NewMiddlewareBuilder().Tracer(tracer).MaxStatementLength(-1).Build()))
+	require.NoError(t, err)
+	require.NoError(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Contains(t, spans[0].Attributes, attribute.String("db.statement", "DELETE FROM"))
+	assert.Equal(t, "RAW", spans[1].Name)
+	assert.Contains(t, spans[1].Attributes, attribute.String("db.statement", "UPDATE "))
+	for _, kv := range spans[2].Attributes {
+		assert.NotEqual(t, attribute.Key("db.statement"), kv.Key)
+	}
+}
+
+func TestMiddlewareBuilder_Sample(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	m := NewMiddlewareBuilder().Tracer(tracer).
+		Sample(func(ctx context.Context, qc *orm.QueryContext) bool {
+			return qc.Type != "DELETE"
+		}).Build()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(m))
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	require.NoError(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+	require.NoError(t, orm.NewUpdater[User](db).Set(orm.Assign("Age", 18)).
+		Where(orm.C("Id").EQ(1)).Exec(ctx).Err())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "UPDATE user", spans[0].Name)
+}
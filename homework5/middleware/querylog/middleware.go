@@ -0,0 +1,104 @@
+package querylog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"log/slog"
+	"time"
+)
+
+// MiddlewareBuilder 构造查询日志的 Middleware
+// 每一个查询都会输出 SQL、参数、耗时、影响行数和错误，
+// 执行时间超过慢查询阈值的查询会以 Warn 级别输出
+type MiddlewareBuilder struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	redact        func(query string, args []any) []any
+	isError       func(err error) bool
+}
+
+// NewMiddlewareBuilder 默认使用 slog.Default，并且不开启慢查询告警
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		logger: slog.Default(),
+		isError: func(err error) bool {
+			return !errors.Is(err, orm.ErrNoRows)
+		},
+	}
+}
+
+// Logger 指定输出日志的 slog.Logger，可以搭配任意的 slog.Handler
+func (m *MiddlewareBuilder) Logger(logger *slog.Logger) *MiddlewareBuilder {
+	m.logger = logger
+	return m
+}
+
+// SlowThreshold 设置慢查询阈值，0 代表不区分慢查询
+func (m *MiddlewareBuilder) SlowThreshold(d time.Duration) *MiddlewareBuilder {
+	m.slowThreshold = d
+	return m
+}
+
+// Redact 设置参数脱敏的方法，返回值会替代原本的参数输出到日志里面
+// 它不会影响真正执行的参数
+func (m *MiddlewareBuilder) Redact(fn func(query string, args []any) []any) *MiddlewareBuilder {
+	m.redact = fn
+	return m
+}
+
+// IsError 判断一个错误是不是要按照 Error 级别输出
+// 默认情况下 ErrNoRows 是正常的业务结果，按照 Info 级别输出
+func (m *MiddlewareBuilder) IsError(fn func(err error) bool) *MiddlewareBuilder {
+	m.isError = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() orm.Middleware {
+	return func(next orm.HandleFunc) orm.HandleFunc {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			start := time.Now()
+			res := next(ctx, qc)
+			duration := time.Since(start)
+			m.log(ctx, qc, res, duration)
+			return res
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) log(ctx context.Context, qc *orm.QueryContext,
+	res *orm.QueryResult, duration time.Duration) {
+	attrs := make([]slog.Attr, 0, 7)
+	attrs = append(attrs, slog.String("type", qc.Type))
+	if qc.Model != nil {
+		attrs = append(attrs, slog.String("table", qc.Model.TableName))
+	}
+	// Build 可以重复调用，这里拿到的就是执行的语句
+	q, err := qc.Builder.Build()
+	if err == nil {
+		args := q.Args
+		if m.redact != nil {
+			args = m.redact(q.SQL, args)
+		}
+		attrs = append(attrs, slog.String("sql", q.SQL), slog.Any("args", args))
+	}
+	attrs = append(attrs, slog.Duration("duration", duration))
+	if r, ok := res.Result.(sql.Result); ok && res.Err == nil {
+		if affected, er := r.RowsAffected(); er == nil {
+			attrs = append(attrs, slog.Int64("rows_affected", affected))
+		}
+	}
+
+	level, msg := slog.LevelInfo, "orm query"
+	if res.Err != nil {
+		attrs = append(attrs, slog.Any("error", res.Err))
+	}
+	switch {
+	case res.Err != nil && m.isError(res.Err):
+		level, msg = slog.LevelError, "orm query failed"
+	case m.slowThreshold > 0 && duration >= m.slowThreshold:
+		level, msg = slog.LevelWarn, "orm slow query"
+	}
+	m.logger.LogAttrs(ctx, level, msg, attrs...)
+}
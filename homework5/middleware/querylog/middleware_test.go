@@ -0,0 +1,113 @@
+package querylog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	orm "exercise/geektime/homework5/version1"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type User struct {
+	Id        int64
+	FirstName string
+	Age       int8
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	buf := &bytes.Buffer{}
+	m := NewMiddlewareBuilder().
+		Logger(slog.New(slog.NewJSONHandler(buf, nil))).
+		SlowThreshold(5 * time.Millisecond).
+		Redact(func(query string, args []any) []any {
+			res := make([]any, len(args))
+			for i := range res {
+				res[i] = "***"
+			}
+			return res
+		}).Build()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(m))
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE .*").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE .*").WillDelayFor(10 * time.Millisecond).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnError(errors.New("exec error"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx := context.Background()
+	require.NoError(t, orm.NewDeleter[User](db).Where(orm.C("Id").EQ(1)).Exec(ctx).Err())
+	require.NoError(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+	assert.Error(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+	_, err = orm.NewSelector[User](db).Get(ctx)
+	assert.Equal(t, orm.ErrNoRows, err)
+
+	dec := json.NewDecoder(buf)
+	var entry map[string]any
+
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "orm query", entry["msg"])
+	assert.Equal(t, "DELETE", entry["type"])
+	assert.Equal(t, "user", entry["table"])
+	assert.Equal(t, "DELETE FROM `user` WHERE `id` = ?;", entry["sql"])
+	// 参数经过了脱敏
+	assert.Equal(t, []any{"***"}, entry["args"])
+	assert.Equal(t, float64(3), entry["rows_affected"])
+	assert.NotNil(t, entry["duration"])
+
+	entry = nil
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "orm slow query", entry["msg"])
+
+	entry = nil
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "orm query failed", entry["msg"])
+	assert.Equal(t, "exec error", entry["error"])
+
+	// 没有数据是正常的业务结果，不按照 Error 输出
+	entry = nil
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "orm query", entry["msg"])
+	assert.Equal(t, orm.ErrNoRows.Error(), entry["error"])
+
+	assert.False(t, dec.More())
+}
+
+func TestMiddlewareBuilder_IsError(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	buf := &bytes.Buffer{}
+	m := NewMiddlewareBuilder().
+		Logger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelError}))).
+		IsError(func(err error) bool { return true }).Build()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddleware(m))
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx := context.Background()
+	require.NoError(t, orm.NewDeleter[User](db).Exec(ctx).Err())
+	_, err = orm.NewSelector[User](db).Get(ctx)
+	assert.Equal(t, orm.ErrNoRows, err)
+
+	// 普通查询被 handler 的级别过滤掉，ErrNoRows 按照 Error 输出
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "SELECT", entry["type"])
+}
@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestQueryContext_Model(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var qcs []*QueryContext
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			qcs = append(qcs, qc)
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	ctx := context.Background()
+	_, err = NewSelector[TestModel](db).Get(ctx)
+	require.NoError(t, err)
+	_, err = NewSelector[TestModel](db).Union(NewSelector[TestModel](db)).GetMulti(ctx)
+	require.NoError(t, err)
+	require.NoError(t, NewInserter[TestModel](db).Values(&TestModel{}).Exec(ctx).Err())
+	require.NoError(t, NewUpdater[TestModel](db).Update(&TestModel{}).Set(C("Age")).Where(C("Id").EQ(1)).Exec(ctx).Err())
+	require.NoError(t, NewDeleter[TestModel](db).Exec(ctx).Err())
+	_, err = RawQuery[TestModel](db, "SELECT `id` FROM `test_model`").Get(ctx)
+	require.NoError(t, err)
+	// 原生查询扫描到基本类型，没有元数据
+	_, err = RawQuery[int64](db, "SELECT `id` FROM `test_model`").Get(ctx)
+	require.NoError(t, err)
+
+	types := make([]string, 0, len(qcs))
+	for idx, qc := range qcs {
+		types = append(types, qc.Type)
+		if idx == len(qcs)-1 {
+			assert.Nil(t, qc.Model)
+			continue
+		}
+		require.NotNil(t, qc.Model)
+		assert.Equal(t, "test_model", qc.Model.TableName)
+	}
+	assert.Equal(t, []string{"SELECT", "SELECT", "INSERT", "UPDATE", "DELETE", "RAW", "RAW"}, types)
+}
@@ -2,6 +2,7 @@
 package model
 
 import (
+	"exercise/geektime/homework5/version1/internal/errs"
 	"reflect"
 )
 
@@ -11,6 +12,32 @@ type Model struct {
 	Fields []*Field
 	FieldMap  map[string]*Field
 	ColumnMap map[string]*Field
+	// PrimaryKeys 主键，联合主键的时候会有多个
+	PrimaryKeys []*Field
+	// AutoIncrement 自增列，一个表最多只有一个
+	AutoIncrement *Field
+}
+
+// refreshKeys 根据字段上的设置重新计算主键和自增列
+// 标签解析完毕以及 Option 修改了设置之后都要调用
+func (m *Model) refreshKeys() error {
+	m.PrimaryKeys = nil
+	m.AutoIncrement = nil
+	for _, fd := range m.Fields {
+		if fd.PrimaryKey {
+			m.PrimaryKeys = append(m.PrimaryKeys, fd)
+		}
+		if fd.AutoIncrement {
+			if m.AutoIncrement != nil {
+				return errs.ErrMultipleAutoIncrement
+			}
+			if !isInteger(fd.Type) {
+				return errs.NewErrInvalidAutoIncrement(fd.GoName, fd.Type)
+			}
+			m.AutoIncrement = fd
+		}
+	}
+	return nil
 }
 
 // Field 字段
@@ -21,12 +48,37 @@ type Field struct {
 	Index int
 	// Offset 相对于对象起始地址的字段偏移量
 	Offset uintptr
+
+	// 下面是列的设置，通过标签或者 Option 指定
+	PrimaryKey bool
+	AutoIncrement bool
+	Nullable bool
+	// Size 列的长度，0 意味着没有设置
+	Size int
+	// Default 列的默认值，ORM 只是记录下来，不会使用
+	Default string
+	// NullAsZero 读到 NULL 的时候设置为零值，而不是返回错误
+	// 只对非指针并且没有实现 sql.Scanner 的字段有意义
+	NullAsZero bool
+	// JSON 列里面存储的是 JSON，读写的时候自动编解码
+	JSON bool
 }
 
 // 我们支持的全部标签上的 key 都放在这里
 // 方便用户查找，和我们后期维护
 const (
 	tagKeyColumn = "column"
+	tagKeySize = "size"
+	tagKeyDefault = "default"
+	// tagKeyPrefix 嵌入结构体展开之后的列名前缀
+	tagKeyPrefix = "prefix"
+
+	// 下面这些是开关，不需要值，例如 orm:"pk,auto_increment"
+	tagKeyPrimaryKey = "pk"
+	tagKeyAutoIncrement = "auto_increment"
+	tagKeyNullable = "nullable"
+	tagKeyNullAsZero = "null_zero"
+	tagKeyJSON = "json"
 )
 
 // 用户自定义一些模型信息的接口，集中放在这里
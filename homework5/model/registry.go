@@ -1,16 +1,25 @@
 package model
 
 import (
-	"errors"
+	"database/sql"
+	"database/sql/driver"
 	"exercise/geektime/homework5/version1/internal/errs"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
 type Option func(m *Model) error
 
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
 // Registry 元数据注册中心的抽象
 type Registry interface {
 	// Get 查找元数据
@@ -61,52 +70,157 @@ func (r *registry) Register(val any, opts ...Option) (*Model, error) {
 
 // parseModel 支持从标签中提取自定义设置
 // 标签形式 orm:"key1=value1,key2=value2"
+// 开关类的设置不需要值，例如 orm:"column=id,pk,auto_increment"
+// orm:"-" 意味着忽略该字段
+// 匿名的结构体字段会被展开，可以通过 orm:"prefix=xxx_" 给展开的列加上前缀，
+// 或者通过 orm:"column=xxx" 让它作为一个普通的列
 func (r *registry) parseModel(val any) (*Model, error) {
-	//	先解析 一下model
 	typ := reflect.TypeOf(val)
-	for typ.Kind() == reflect.Pointer {
-		typ = typ.Elem()
-	}
-	if typ.Kind() != reflect.Struct {
-		return nil, errors.New("ParseModel 只支持 解析 Struct")
+	if typ == nil || typ.Kind() != reflect.Pointer ||
+		typ.Elem().Kind() != reflect.Struct {
+		return nil, errs.ErrPointerOnly
 	}
+	typ = typ.Elem()
 	num := typ.NumField() //结构体中 有 num 个 字段
 
-	FieldMap := make(map[string]*Field, num)
-	ColumnMap := make(map[string]*Field, num)
+	res := &Model{
+		Fields:    make([]*Field, 0, num),
+		FieldMap:  make(map[string]*Field, num),
+		ColumnMap: make(map[string]*Field, num),
+	}
+	ambiguous := make(map[string]int)
+	if err := r.parseFields(res, typ, 0, "", 0, make(map[string]int, num), ambiguous); err != nil {
+		return nil, err
+	}
+	if len(ambiguous) > 0 {
+		// 按照字段的顺序报告，保证错误信息是稳定的
+		for _, fd := range res.Fields {
+			if _, ok := ambiguous[fd.GoName]; ok {
+				return nil, errs.NewErrAmbiguousField(fd.GoName)
+			}
+		}
+	}
+	if err := res.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	var tableName string
+	if tn, ok := val.(TableName); ok {
+		tableName = tn.TableName()
+	}
+
+	if tableName == "" {
+		tableName = underscoreName(typ.Name())
+	}
+	res.TableName = tableName
+	return res, nil
+}
 
-	for i := 0; i < num; i++ {
+// parseFields 解析 typ 的字段并且加入到 m 里面
+// 匿名的结构体字段会被展开，效果和 Go 的字段提升一样，
+// offset 是 typ 相对于最外层结构体的偏移量，prefix 是展开之后列名的前缀，
+// depths 记录了每个字段的嵌套深度，同名字段浅的覆盖深的。
+// 和 Go 一样，同一深度的同名字段是有歧义的，记录在 ambiguous 里面，
+// 除非后面有更浅的同名字段覆盖它们
+func (r *registry) parseFields(m *Model, typ reflect.Type, offset uintptr,
+	prefix string, depth int, depths map[string]int, ambiguous map[string]int) error {
+	for i := 0; i < typ.NumField(); i++ {
 		fd := typ.Field(i)
+		if fd.Tag.Get("orm") == "-" {
+			continue
+		}
 		tag, err := r.parseTag(fd.Tag)
-		//fmt.Println(tag, err, "s")
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if fd.Anonymous && r.shouldFlatten(fd.Type, tag) {
+			if fd.Type.Kind() == reflect.Pointer {
+				return errs.NewErrUnsupportedEmbedded(fd.Name)
+			}
+			err = r.parseFields(m, fd.Type, offset+fd.Offset,
+				prefix+tag[tagKeyPrefix], depth+1, depths, ambiguous)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if d, ok := depths[fd.Name]; ok && d <= depth {
+			if d == depth {
+				ambiguous[fd.Name] = depth
+			}
+			// 外层已经有同名字段了
+			continue
 		}
-		tagname := tag["column"]
+		delete(ambiguous, fd.Name)
+		tagname := tag[tagKeyColumn]
 		if tagname == "" {
 			tagname = underscoreName(fd.Name)
 		}
 		field := &Field{
-			ColName: tagname,
+			ColName: prefix + tagname,
 			GoName:  fd.Name,
 			Type:    fd.Type,
-			Offset:  fd.Offset,
+			Index:   len(m.Fields),
+			Offset:  offset + fd.Offset,
+			Default: tag[tagKeyDefault],
+		}
+		_, field.PrimaryKey = tag[tagKeyPrimaryKey]
+		_, field.AutoIncrement = tag[tagKeyAutoIncrement]
+		_, field.Nullable = tag[tagKeyNullable]
+		_, field.NullAsZero = tag[tagKeyNullAsZero]
+		_, field.JSON = tag[tagKeyJSON]
+		if size, ok := tag[tagKeySize]; ok {
+			field.Size, err = strconv.Atoi(size)
+			if err != nil {
+				return errs.NewErrInvalidTagContent(tagKeySize + "=" + size)
+			}
+		}
+
+		if old, ok := m.FieldMap[fd.Name]; ok {
+			// 之前嵌入的结构体里面有同名字段，被当前这个覆盖
+			field.Index = old.Index
+			m.Fields[old.Index] = field
+			delete(m.ColumnMap, old.ColName)
+		} else {
+			m.Fields = append(m.Fields, field)
 		}
-		FieldMap[fd.Name] = field
-		ColumnMap[tagname] = field
+		depths[fd.Name] = depth
+		m.FieldMap[fd.Name] = field
+		m.ColumnMap[field.ColName] = field
 	}
-	res := &Model{FieldMap: FieldMap, ColumnMap: ColumnMap, TableName: ""}
+	return nil
+}
 
-	var tableName string
-	if tn, ok := val.(TableName); ok {
-		tableName = tn.TableName()
+// isInteger 判断是不是整数类型，自增列的值会被写回到字段里面，所以只能是整数
+// 指针和 sql.NullInt64 这种类型都不行，因为插入之后才能发现写不回去
+func isInteger(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
 	}
+}
 
-	if tableName == "" {
-		tableName = underscoreName(typ.Name())
+// shouldFlatten 判断匿名字段是否需要展开
+// 通过 column 指定了列名，标记为 json 的，或者类型自己能够处理读写的，都当做一个普通的列
+func (r *registry) shouldFlatten(typ reflect.Type, tag map[string]string) bool {
+	if _, ok := tag[tagKeyColumn]; ok {
+		return false
 	}
-	res.TableName = tableName
-	return res, nil
+	if _, ok := tag[tagKeyJSON]; ok {
+		return false
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	ptr := reflect.PointerTo(typ)
+	return !ptr.Implements(scannerType) && !ptr.Implements(valuerType)
 }
 
 func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
@@ -115,13 +229,18 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 		// 返回一个空的 map，这样调用者就不需要判断 nil 了
 		return map[string]string{}, nil
 	}
-	// 这个初始化容量就是我们支持的 key 的数量，
-	// 现在只有一个，所以我们初始化为 1
-	res := make(map[string]string, 1)
+	// 这个初始化容量就是我们支持的 key 的数量
+	res := make(map[string]string, 6)
 
 	// 接下来就是字符串处理了
 	pairs := strings.Split(ormTag, ",")
 	for _, pair := range pairs {
+		switch pair {
+		case tagKeyPrimaryKey, tagKeyAutoIncrement, tagKeyNullable, tagKeyNullAsZero,
+			tagKeyJSON:
+			res[pair] = ""
+			continue
+		}
 		kv := strings.Split(pair, "=")
 		if len(kv) != 2 {
 			return nil, errs.NewErrInvalidTagContent(pair)
@@ -168,3 +287,96 @@ func WithColumnName(field string, columnName string) Option {
 		return nil
 	}
 }
+
+// WithPrimaryKey 指定主键，会覆盖标签里面的主键设置
+// 传入多个字段的时候是联合主键
+func WithPrimaryKey(fields ...string) Option {
+	return func(model *Model) error {
+		for _, fd := range model.Fields {
+			fd.PrimaryKey = false
+		}
+		for _, name := range fields {
+			fd, ok := model.FieldMap[name]
+			if !ok {
+				return errs.NewErrUnknownField(name)
+			}
+			fd.PrimaryKey = true
+		}
+		return model.refreshKeys()
+	}
+}
+
+// WithAutoIncrement 指定自增列，会覆盖标签里面的自增设置
+func WithAutoIncrement(field string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		for _, f := range model.Fields {
+			f.AutoIncrement = false
+		}
+		fd.AutoIncrement = true
+		return model.refreshKeys()
+	}
+}
+
+// WithNullable 指定列允许为 NULL
+func WithNullable(field string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		fd.Nullable = true
+		return nil
+	}
+}
+
+// WithNullAsZero 指定读到 NULL 的时候使用零值
+func WithNullAsZero(field string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		fd.NullAsZero = true
+		return nil
+	}
+}
+
+// WithJSON 指定列存储的是 JSON
+func WithJSON(field string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		fd.JSON = true
+		return nil
+	}
+}
+
+// WithColumnSize 指定列的长度
+func WithColumnSize(field string, size int) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		fd.Size = size
+		return nil
+	}
+}
+
+// WithColumnDefault 指定列的默认值
+func WithColumnDefault(field string, val string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		fd.Default = val
+		return nil
+	}
+}
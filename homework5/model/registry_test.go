@@ -92,6 +92,273 @@ func TestWithColumnName(t *testing.T) {
 	}
 }
 
+func TestKeyOptions(t *testing.T) {
+	type KeyModel struct {
+		ID       uint64 `orm:"pk,auto_increment"`
+		TenantID uint64
+		Name     string
+	}
+	testCases := []struct {
+		name      string
+		opts      []Option
+		wantPKs   []string
+		wantAutoI string
+		wantErr   error
+	}{
+		{
+			name:      "tag",
+			wantPKs:   []string{"ID"},
+			wantAutoI: "ID",
+		},
+		{
+			// Option 覆盖标签
+			name:      "composite primary key",
+			opts:      []Option{WithPrimaryKey("TenantID", "ID")},
+			wantPKs:   []string{"ID", "TenantID"},
+			wantAutoI: "ID",
+		},
+		{
+			name:      "auto increment",
+			opts:      []Option{WithAutoIncrement("TenantID")},
+			wantPKs:   []string{"ID"},
+			wantAutoI: "TenantID",
+		},
+		{
+			name:    "invalid primary key",
+			opts:    []Option{WithPrimaryKey("Invalid")},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid auto increment",
+			opts:    []Option{WithAutoIncrement("Invalid")},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// 自增列的值要写回字段，所以只能是整数
+			name:    "string auto increment",
+			opts:    []Option{WithAutoIncrement("Name")},
+			wantErr: errs.NewErrInvalidAutoIncrement("Name", reflect.TypeOf("")),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			m, err := r.Register(&KeyModel{}, tc.opts...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			pks := make([]string, 0, len(m.PrimaryKeys))
+			for _, pk := range m.PrimaryKeys {
+				pks = append(pks, pk.GoName)
+			}
+			assert.Equal(t, tc.wantPKs, pks)
+			assert.Equal(t, tc.wantAutoI, m.AutoIncrement.GoName)
+		})
+	}
+}
+
+func TestColumnOptions(t *testing.T) {
+	type ColumnModel struct {
+		Name  string
+		Attrs map[string]string
+		Tags  []string `orm:"json,null_zero"`
+	}
+	m, err := NewRegistry().Register(&ColumnModel{},
+		WithNullable("Name"), WithColumnSize("Name", 32), WithColumnDefault("Name", "tom"),
+		WithNullAsZero("Name"), WithJSON("Attrs"))
+	assert.NoError(t, err)
+	fd := m.FieldMap["Name"]
+	assert.True(t, fd.Nullable)
+	assert.True(t, fd.NullAsZero)
+	assert.Equal(t, 32, fd.Size)
+	assert.Equal(t, "tom", fd.Default)
+	assert.True(t, m.FieldMap["Attrs"].JSON)
+	assert.True(t, m.FieldMap["Tags"].JSON)
+	assert.True(t, m.FieldMap["Tags"].NullAsZero)
+
+	_, err = NewRegistry().Register(&ColumnModel{}, WithJSON("Invalid"))
+	assert.Equal(t, errs.NewErrUnknownField("Invalid"), err)
+}
+
+func TestRegistry_embedded(t *testing.T) {
+	type BaseEntity struct {
+		ID        uint64 `orm:"pk,auto_increment"`
+		CreatedAt int64
+	}
+	type Address struct {
+		City   string
+		Street string `orm:"column=road"`
+	}
+	type Attr struct {
+		Color string
+	}
+	type Author struct {
+		Name string
+	}
+	type Publisher struct {
+		Name string
+	}
+
+	testCases := []struct {
+		name string
+		val  any
+		// 期望的列名，按照顺序
+		wantCols []string
+		// 期望的偏移量，用于检测 unsafe 读写
+		wantOffsets []uintptr
+		wantPKs     []string
+		wantErr     error
+	}{
+		{
+			name: "embedded",
+			val: func() any {
+				type User struct {
+					BaseEntity
+					Name string
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"i_d", "created_at", "name"},
+			wantOffsets: []uintptr{0, 8, 16},
+			wantPKs:     []string{"ID"},
+		},
+		{
+			name: "prefix",
+			val: func() any {
+				type User struct {
+					Name    string
+					Address `orm:"prefix=addr_"`
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"name", "addr_city", "addr_road"},
+			wantOffsets: []uintptr{0, 16, 32},
+		},
+		{
+			name: "nested",
+			val: func() any {
+				type Base struct {
+					BaseEntity
+					Address `orm:"prefix=addr_"`
+				}
+				type User struct {
+					Base
+					Name string
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"i_d", "created_at", "addr_city", "addr_road", "name"},
+			wantOffsets: []uintptr{0, 8, 16, 32, 48},
+			wantPKs:     []string{"ID"},
+		},
+		{
+			// 指定了列名，就不会展开
+			name: "column",
+			val: func() any {
+				type User struct {
+					Attr `orm:"column=attr"`
+					Name string
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"attr", "name"},
+			wantOffsets: []uintptr{0, 16},
+		},
+		{
+			// 外层的同名字段覆盖嵌入的字段
+			name: "shadow",
+			val: func() any {
+				type User struct {
+					BaseEntity
+					CreatedAt string `orm:"column=ctime"`
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"i_d", "ctime"},
+			wantOffsets: []uintptr{0, 16},
+			wantPKs:     []string{"ID"},
+		},
+		{
+			name: "ignore",
+			val: func() any {
+				type User struct {
+					BaseEntity `orm:"-"`
+					Name       string
+					Extra      string `orm:"-"`
+				}
+				return &User{}
+			}(),
+			wantCols:    []string{"name"},
+			wantOffsets: []uintptr{16},
+		},
+		{
+			// 和 Go 一样，同一层级的同名字段有歧义
+			name: "ambiguous",
+			val: func() any {
+				type Book struct {
+					Author    `orm:"prefix=author_"`
+					Publisher `orm:"prefix=publisher_"`
+				}
+				return &Book{}
+			}(),
+			wantErr: errs.NewErrAmbiguousField("Name"),
+		},
+		{
+			// 外层的同名字段消除了歧义
+			name: "ambiguous shadowed",
+			val: func() any {
+				type Book struct {
+					Author    `orm:"prefix=author_"`
+					Publisher `orm:"prefix=publisher_"`
+					Name      string
+				}
+				return &Book{}
+			}(),
+			wantCols:    []string{"name"},
+			wantOffsets: []uintptr{32},
+		},
+		{
+			name: "pointer",
+			val: func() any {
+				type User struct {
+					*BaseEntity
+					Name string
+				}
+				return &User{}
+			}(),
+			wantErr: errs.NewErrUnsupportedEmbedded("BaseEntity"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewRegistry().Get(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			cols := make([]string, 0, len(m.Fields))
+			offsets := make([]uintptr, 0, len(m.Fields))
+			for i, fd := range m.Fields {
+				assert.Equal(t, i, fd.Index)
+				assert.Equal(t, fd, m.ColumnMap[fd.ColName])
+				assert.Equal(t, fd, m.FieldMap[fd.GoName])
+				cols = append(cols, fd.ColName)
+				offsets = append(offsets, fd.Offset)
+			}
+			assert.Equal(t, tc.wantCols, cols)
+			assert.Equal(t, tc.wantOffsets, offsets)
+			assert.Equal(t, len(cols), len(m.ColumnMap))
+			var pks []string
+			for _, pk := range m.PrimaryKeys {
+				pks = append(pks, pk.GoName)
+			}
+			assert.Equal(t, tc.wantPKs, pks)
+		})
+	}
+}
+
 func TestRegistry_get(t *testing.T) {
 	var tm TestModel
 	testCases := []struct {
@@ -264,6 +531,86 @@ func TestRegistry_get(t *testing.T) {
 			},
 		},
 
+		{
+			// 主键和自增这种开关类的设置不需要值
+			name: "key tags",
+			val: func() any {
+				type KeyTag struct {
+					ID   uint64 `orm:"column=id,pk,auto_increment"`
+					Name string `orm:"nullable,size=64,default=tom"`
+				}
+				return &KeyTag{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{
+					ColName:       "id",
+					GoName:        "ID",
+					Type:          reflect.TypeOf(uint64(0)),
+					PrimaryKey:    true,
+					AutoIncrement: true,
+				}
+				name := &Field{
+					ColName:  "name",
+					GoName:   "Name",
+					Type:     reflect.TypeOf(""),
+					Index:    1,
+					Offset:   8,
+					Nullable: true,
+					Size:     64,
+					Default:  "tom",
+				}
+				return &Model{
+					TableName:     "key_tag",
+					Fields:        []*Field{id, name},
+					FieldMap:      map[string]*Field{"ID": id, "Name": name},
+					ColumnMap:     map[string]*Field{"id": id, "name": name},
+					PrimaryKeys:   []*Field{id},
+					AutoIncrement: id,
+				}
+			}(),
+		},
+		{
+			name: "invalid size",
+			val: func() any {
+				type InvalidSize struct {
+					Name string `orm:"size=abc"`
+				}
+				return &InvalidSize{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("size=abc"),
+		},
+		{
+			name: "multiple auto increment",
+			val: func() any {
+				type MultipleAutoIncrement struct {
+					ID  uint64 `orm:"auto_increment"`
+					Seq uint64 `orm:"auto_increment"`
+				}
+				return &MultipleAutoIncrement{}
+			}(),
+			wantErr: errs.ErrMultipleAutoIncrement,
+		},
+		{
+			name: "pointer auto increment",
+			val: func() any {
+				type PointerAutoIncrement struct {
+					ID *int64 `orm:"auto_increment"`
+				}
+				return &PointerAutoIncrement{}
+			}(),
+			wantErr: errs.NewErrInvalidAutoIncrement("ID", reflect.TypeOf(new(int64))),
+		},
+		{
+			name: "null int auto increment",
+			val: func() any {
+				type NullAutoIncrement struct {
+					ID sql.NullInt64 `orm:"auto_increment"`
+				}
+				return &NullAutoIncrement{}
+			}(),
+			wantErr: errs.NewErrInvalidAutoIncrement("ID", reflect.TypeOf(sql.NullInt64{})),
+		},
+
 		// 利用接口自定义模型信息
 		{
 			name: "table name",
@@ -0,0 +1,41 @@
+package orm
+
+// OrderBy 代表 ORDER BY 里面的一个排序项
+// 通过 Asc 和 Desc 来创建
+type OrderBy struct {
+	expr  Selectable
+	order string
+	// nulls 是 NULL 值的排序位置，FIRST 或者 LAST
+	// 空字符串意味着使用数据库的默认行为
+	nulls string
+}
+
+// Asc 升序，s 可以是 Column，Aggregate 或者 RawExpr
+func Asc(s Selectable) OrderBy {
+	return OrderBy{
+		expr:  s,
+		order: "ASC",
+	}
+}
+
+// Desc 降序，s 可以是 Column，Aggregate 或者 RawExpr
+func Desc(s Selectable) OrderBy {
+	return OrderBy{
+		expr:  s,
+		order: "DESC",
+	}
+}
+
+// NullsFirst NULL 值排在前面
+// 不支持 NULLS FIRST 语法的数据库会由方言来模拟
+func (o OrderBy) NullsFirst() OrderBy {
+	o.nulls = "FIRST"
+	return o
+}
+
+// NullsLast NULL 值排在后面
+// 不支持 NULLS LAST 语法的数据库会由方言来模拟
+func (o OrderBy) NullsLast() OrderBy {
+	o.nulls = "LAST"
+	return o
+}
@@ -1,20 +1,34 @@
 package orm
 
+import "reflect"
+
 // op 代表操作符
 type op string
 
 // 后面可以每次支持新的操作符就加一个
 const (
-	opEQ    = "="
-	opLT    = "<"
-	opGT    = ">"
-	opIN    = "IN"
-	opExist = "EXIST"
-	opAND   = "AND"
-	opOR    = "OR"
-	opNOT   = "NOT"
-	opAdd   = "+"
-	opMulti = "*"
+	opEQ        = "="
+	opNEQ       = "!="
+	opLT        = "<"
+	opLTEQ      = "<="
+	opGT        = ">"
+	opGTEQ      = ">="
+	opIN        = "IN"
+	opNotIN     = "NOT IN"
+	opLike      = "LIKE"
+	opNotLike   = "NOT LIKE"
+	opBetween   = "BETWEEN"
+	opIsNull    = "IS NULL"
+	opIsNotNull = "IS NOT NULL"
+	opExist     = "EXIST"
+	opAND       = "AND"
+	opOR        = "OR"
+	opNOT       = "NOT"
+	opAdd       = "+"
+	opSub       = "-"
+	opMulti     = "*"
+	opDiv       = "/"
+	opMod       = "%"
 )
 
 func (o op) String() string {
@@ -71,3 +85,81 @@ func (p Predicate) Or(right Predicate) Predicate {
 		right: right,
 	}
 }
+
+// newPredicate 构造 left op arg 形式的谓词，arg 可以是值，也可以是 Expression
+func newPredicate(left Expression, o op, arg any) Predicate {
+	return Predicate{
+		left:  left,
+		op:    o,
+		right: exprOf(arg),
+	}
+}
+
+// nullPredicate 构造 IS NULL 和 IS NOT NULL，它们没有右边
+func nullPredicate(left Expression, o op) Predicate {
+	return Predicate{
+		left: left,
+		op:   o,
+	}
+}
+
+// betweenPredicate 构造 left BETWEEN lo AND hi
+func betweenPredicate(left Expression, lo, hi any) Predicate {
+	return Predicate{
+		left: left,
+		op:   opBetween,
+		right: betweenExpr{
+			lo: exprOf(lo),
+			hi: exprOf(hi),
+		},
+	}
+}
+
+// inPredicate 构造 IN 和 NOT IN
+// 只传入一个切片的时候会被展开，例如 In([]int{1, 2}) 和 In(1, 2) 是一样的。
+// 没有任何值的时候，IN 是一个恒假的谓词，NOT IN 是一个恒真的谓词，
+// 因为 IN () 在大多数数据库里面都是语法错误
+func inPredicate(left Expression, o op, vals []any) Predicate {
+	vals = flattenValues(vals)
+	if len(vals) == 0 {
+		if o == opNotIN {
+			return Raw("1 = 1").AsPredicate()
+		}
+		return Raw("1 = 0").AsPredicate()
+	}
+	return Predicate{
+		left:  left,
+		op:    o,
+		right: valuesExpr{vals: vals},
+	}
+}
+
+func flattenValues(vals []any) []any {
+	if len(vals) != 1 {
+		return vals
+	}
+	v := reflect.ValueOf(vals[0])
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return vals
+	}
+	res := make([]any, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		res = append(res, v.Index(i).Interface())
+	}
+	return res
+}
+
+// valuesExpr 代表一组值，构造出来是 (?,?,?)
+type valuesExpr struct {
+	vals []any
+}
+
+func (valuesExpr) expr() {}
+
+// betweenExpr 是 BETWEEN 的右边，构造出来是 lo AND hi
+type betweenExpr struct {
+	lo Expression
+	hi Expression
+}
+
+func (betweenExpr) expr() {}
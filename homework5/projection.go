@@ -0,0 +1,43 @@
+package orm
+
+import "context"
+
+// Projectable 代表结果可以扫描到任意类型里面的查询，
+// 例如 *Selector[T]，*SetQuery[T] 和 *RawQuerier[T]
+type Projectable interface {
+	QueryBuilder
+	// projection 返回执行查询需要的 core，session 和 QueryContext
+	projection() (core, session, *QueryContext)
+}
+
+var (
+	_ Projectable = &Selector[any]{}
+	_ Projectable = &SetQuery[any]{}
+	_ Projectable = &RawQuerier[any]{}
+)
+
+// GetAs 执行查询，并且把第一行扫描到 V 里面
+// V 是结构体的时候，结果集的列按照 V 的元数据映射到字段上，
+// 所以 JOIN 的时候可以通过别名把不同表的列映射到 V 的字段上，例如
+// GetAs[OrderView](ctx, NewSelector[Order](db).Select(t2.C("Name").As("user_name")))
+// V 也可以是 int64，string 这种基本类型，用于 COUNT 这种只有一列的查询。
+// 注意 MAX，SUM 这种聚合函数在没有数据的时候返回 NULL，
+// 这时候 V 要用 sql.NullInt64 或者 *int64 这种能够表达 NULL 的类型，否则会返回错误
+func GetAs[V any](ctx context.Context, q Projectable) (*V, error) {
+	c, sess, qc := q.projection()
+	res := get[V](ctx, c, sess, qc)
+	if res.Result != nil {
+		return res.Result.(*V), res.Err
+	}
+	return nil, res.Err
+}
+
+// GetMultiAs 执行查询，并且把所有的行扫描到 V 里面，规则和 GetAs 一样
+func GetMultiAs[V any](ctx context.Context, q Projectable) ([]*V, error) {
+	c, sess, qc := q.projection()
+	res := getMulti[V](ctx, c, sess, qc)
+	if res.Result != nil {
+		return res.Result.([]*V), res.Err
+	}
+	return nil, res.Err
+}
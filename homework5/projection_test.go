@@ -0,0 +1,133 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestGetAs(t *testing.T) {
+	type Order struct {
+		Id     int64
+		UserId int64
+	}
+	type User struct {
+		Id   int64
+		Name string
+	}
+	type OrderView struct {
+		Id       int64
+		UserName string
+	}
+
+	valuers := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflectValuer()},
+		},
+	}
+
+	for _, v := range valuers {
+		t.Run(v.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, v.opts...)
+			require.NoError(t, err)
+
+			t1 := TableOf(&Order{}).As("t1")
+			t2 := TableOf(&User{}).As("t2")
+			s := NewSelector[Order](db).
+				Select(t1.C("Id"), t2.C("Name").As("user_name")).
+				From(t1.Join(t2).On(t1.C("UserId").EQ(t2.C("Id"))))
+			wantSQL := regexp.QuoteMeta("SELECT `t1`.`id`,`t2`.`name` AS `user_name` FROM (`order` AS `t1` JOIN `user` AS `t2` ON `t1`.`user_id` = `t2`.`id`);")
+
+			rows := sqlmock.NewRows([]string{"id", "user_name"}).AddRow([]byte("1"), []byte("Tom"))
+			mock.ExpectQuery(wantSQL).WillReturnRows(rows)
+			res, err := GetAs[OrderView](context.Background(), s)
+			require.NoError(t, err)
+			assert.Equal(t, &OrderView{Id: 1, UserName: "Tom"}, res)
+
+			rows = sqlmock.NewRows([]string{"id", "user_name"}).
+				AddRow([]byte("1"), []byte("Tom")).AddRow([]byte("2"), []byte("Jerry"))
+			mock.ExpectQuery(wantSQL).WillReturnRows(rows)
+			multi, err := GetMultiAs[OrderView](context.Background(), s)
+			require.NoError(t, err)
+			assert.Equal(t, []*OrderView{{Id: 1, UserName: "Tom"}, {Id: 2, UserName: "Jerry"}}, multi)
+
+			// 结果集里面有 V 没有的列
+			rows = sqlmock.NewRows([]string{"id", "name"}).AddRow([]byte("1"), []byte("Tom"))
+			mock.ExpectQuery(".*").WillReturnRows(rows)
+			_, err = GetAs[OrderView](context.Background(), s)
+			assert.Equal(t, errs.NewErrUnknownColumn("name"), err)
+		})
+	}
+}
+
+func TestGetAs_Scalar(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(`id`) FROM `test_model` WHERE `age` > ?;")).
+		WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"COUNT(`id`)"}).AddRow([]byte("12")))
+	cnt, err := GetAs[int64](context.Background(),
+		NewSelector[TestModel](db).Select(Count("Id")).Where(C("Age").GT(18)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), *cnt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT `first_name` FROM `test_model`;")).
+		WillReturnRows(sqlmock.NewRows([]string{"first_name"}).
+			AddRow([]byte("Tom")).AddRow([]byte("Jerry")))
+	names, err := GetMultiAs[string](context.Background(),
+		NewSelector[TestModel](db).Select(C("FirstName")).Distinct())
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", *names[0])
+	assert.Equal(t, "Jerry", *names[1])
+
+	// sql.Scanner 也是直接扫描
+	mock.ExpectQuery("SELECT MAX").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	maxAge, err := GetAs[sql.NullInt64](context.Background(),
+		RawQuery[TestModel](db, "SELECT MAX(`age`) FROM `test_model`"))
+	require.NoError(t, err)
+	assert.Equal(t, sql.NullInt64{}, *maxAge)
+
+	// 基本类型不能接收 NULL，返回错误的时候不会返回零值
+	mock.ExpectQuery("SELECT MAX").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	maxId, err := GetAs[int64](context.Background(),
+		NewSelector[TestModel](db).Select(Max("Id")))
+	assert.Error(t, err)
+	assert.Nil(t, maxId)
+
+	mock.ExpectQuery("SELECT MAX").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	maxIdPtr, err := GetAs[*int64](context.Background(),
+		NewSelector[TestModel](db).Select(Max("Id")))
+	require.NoError(t, err)
+	assert.Nil(t, *maxIdPtr)
+
+	// 基本类型只能有一列
+	mock.ExpectQuery(".*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "age"}).AddRow([]byte("1"), []byte("18")))
+	_, err = GetAs[int64](context.Background(), NewSelector[TestModel](db).Select(C("Id"), C("Age")))
+	assert.Equal(t, errs.ErrTooManyReturnedColumns, err)
+
+	mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"cnt"}))
+	_, err = GetAs[int64](context.Background(), NewSelector[TestModel](db).Select(Count("Id")))
+	assert.Equal(t, ErrNoRows, err)
+}
@@ -16,17 +16,11 @@ type RawQuerier[T any] struct {
 }
 
 func (r *RawQuerier[T]) Exec(ctx context.Context) Result {
-	return exec(ctx, r.sess, r.core, &QueryContext{
-		Builder: r,
-		Type: "RAW",
-	})
+	return exec(ctx, r.sess, r.core, newQueryContext[T](r.core, "RAW", r))
 }
 
 func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
-	res := get[T](ctx, r.core, r.sess, &QueryContext{
-		Builder: r,
-		Type: "RAW",
-	})
+	res := get[T](ctx, r.core, r.sess, newQueryContext[T](r.core, "RAW", r))
 	if res.Result != nil {
 		return res.Result.(*T), res.Err
 	}
@@ -34,8 +28,15 @@ func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
 }
 
 func (r *RawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	// TODO implement me
-	panic("implement me")
+	res := getMulti[T](ctx, r.core, r.sess, newQueryContext[T](r.core, "RAW", r))
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
+}
+
+func (r *RawQuerier[T]) projection() (core, session, *QueryContext) {
+	return r.core, r.sess, newQueryContext[T](r.core, "RAW", r)
 }
 
 func (r *RawQuerier[T]) Build() (*Query, error) {
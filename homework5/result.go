@@ -1,7 +1,10 @@
 
 package orm
 
-import "database/sql"
+import (
+	"database/sql"
+	"exercise/geektime/homework5/version1/internal/errs"
+)
 
 type Result struct {
 	err error
@@ -25,3 +28,31 @@ func (r Result) RowsAffected() (int64, error) {
 	}
 	return r.res.RowsAffected()
 }
+
+// returningResult 是 RETURNING 语句的执行结果
+// 返回的列已经写回到了对象里面，所以不支持 LastInsertId
+type returningResult struct {
+	affected int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) {
+	return 0, errs.NewErrUnsupportedByDialect("LastInsertId")
+}
+
+func (r returningResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+// batchResult 是分批执行的结果
+// 每一批的自增主键已经写回到了对象里面，所以不支持 LastInsertId
+type batchResult struct {
+	affected int64
+}
+
+func (r batchResult) LastInsertId() (int64, error) {
+	return 0, errs.NewErrUnsupportedByDialect("分批执行的 LastInsertId")
+}
+
+func (r batchResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
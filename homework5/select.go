@@ -2,7 +2,6 @@ package orm
 
 import (
 	"context"
-	"database/sql"
 	"exercise/geektime/homework5/version1/internal/errs"
 )
 
@@ -14,9 +13,14 @@ type Selector[T any] struct {
 	having  []Predicate
 	columns []Selectable
 	groupBy []Column
+	orderBy []OrderBy
 	offset  int
 	limit   int
 	sess    session
+
+	// distinct 为 true 的时候生成 SELECT DISTINCT
+	distinct bool
+	ctes     []CTE
 }
 
 func (s *Selector[T]) Select(cols ...Selectable) *Selector[T] {
@@ -24,6 +28,18 @@ func (s *Selector[T]) Select(cols ...Selectable) *Selector[T] {
 	return s
 }
 
+// With 定义查询里面使用的 CTE
+func (s *Selector[T]) With(ctes ...CTE) *Selector[T] {
+	s.ctes = ctes
+	return s
+}
+
+// Distinct 去掉重复的行
+func (s *Selector[T]) Distinct() *Selector[T] {
+	s.distinct = true
+	return s
+}
+
 // From 指定表名，如果是空字符串，那么将会使用默认表名
 func (s *Selector[T]) From(tbl TableReference) *Selector[T] {
 	s.table = tbl
@@ -31,11 +47,20 @@ func (s *Selector[T]) From(tbl TableReference) *Selector[T] {
 }
 
 func (s *Selector[T]) Build() (*Query, error) {
+	return s.buildAt(0)
+}
 
-	if len(s.sb.String()) > 0 {
-		return &Query{SQL: s.sb.String(), Args: s.args}, nil
-	}
+// buildAt 在副本上构造，不修改 Selector 本身
+// 同一个 Selector 可能被构造多次，例如作为子查询被多处引用，
+// 每次引用的时候占位符的编号都可能不同，也可能被多个 goroutine 同时构造
+func (s *Selector[T]) buildAt(offset int) (*Query, error) {
+	cp := *s
+	cp.builder = s.builder.clone()
+	cp.argOffset = offset
+	return cp.build()
+}
 
+func (s *Selector[T]) build() (*Query, error) {
 	var err error
 	if s.model == nil {
 		s.model, err = s.r.Get(new(T))
@@ -44,7 +69,15 @@ func (s *Selector[T]) Build() (*Query, error) {
 		}
 	}
 
+	if len(s.ctes) > 0 {
+		if err = s.buildWith(); err != nil {
+			return nil, err
+		}
+	}
 	s.sb.WriteString("SELECT ")
+	if s.distinct {
+		s.sb.WriteString("DISTINCT ")
+	}
 	if err = s.buildColumns(); err != nil {
 		return nil, err
 	}
@@ -80,14 +113,20 @@ func (s *Selector[T]) Build() (*Query, error) {
 		}
 	}
 
+	if len(s.orderBy) > 0 {
+		if err = s.buildOrderBy(s.orderBy); err != nil {
+			return nil, err
+		}
+	}
+
 	if s.limit > 0 {
-		s.sb.WriteString(" LIMIT ?")
-		s.addArgs(s.limit)
+		s.sb.WriteString(" LIMIT ")
+		s.parameter(s.limit)
 	}
 
 	if s.offset > 0 {
-		s.sb.WriteString(" OFFSET ?")
-		s.addArgs(s.offset)
+		s.sb.WriteString(" OFFSET ")
+		s.parameter(s.offset)
 	}
 
 	s.sb.WriteString(";")
@@ -115,23 +154,51 @@ func (s *Selector[T]) buildTable(table TableReference) error {
 		return s.buildJoin(tab)
 	case Subquery:
 		s.sb.WriteByte('(')
-		query, err := tab.s.Build()
-		if err != nil {
+		if err := s.buildSubquery(tab.s); err != nil {
 			return err
 		}
-		s.sb.WriteString(query.SQL[:len(query.SQL)-1])
 		s.sb.WriteByte(')')
 
 		if tab.alias != "" {
 			s.sb.WriteString(" AS ")
 			s.quote(tab.alias)
 		}
+	case CTE:
+		s.quote(tab.name)
 	default:
 		return errs.NewErrUnsupportedExpressionType(tab)
 	}
 	return nil
 }
 
+// buildWith 构造 WITH 子句，包括后面的空格
+// 只要有一个 CTE 是递归的，就要使用 WITH RECURSIVE
+func (s *Selector[T]) buildWith() error {
+	s.sb.WriteString("WITH ")
+	for _, cte := range s.ctes {
+		if cte.recursive {
+			s.sb.WriteString("RECURSIVE ")
+			break
+		}
+	}
+	for i, cte := range s.ctes {
+		if i > 0 {
+			s.sb.WriteByte(',')
+		}
+		if cte.query == nil {
+			return errs.NewErrUndefinedCTE(cte.name)
+		}
+		s.quote(cte.name)
+		s.sb.WriteString(" AS (")
+		if err := s.buildSubquery(cte.query); err != nil {
+			return err
+		}
+		s.sb.WriteByte(')')
+	}
+	s.sb.WriteByte(' ')
+	return nil
+}
+
 func (s *Selector[T]) buildJoin(tab Join) error {
 	s.sb.WriteByte('(')
 	if err := s.buildTable(tab.left); err != nil {
@@ -187,6 +254,26 @@ func (s *Selector[T]) buildColumns() error {
 			}
 		case RawExpr:
 			s.raw(val)
+		case MathExpr:
+			if err := s.buildMathExpr(val); err != nil {
+				return err
+			}
+			s.buildAs(val.alias)
+		case FuncExpr:
+			if err := s.dialect.buildFunc(&s.builder, val); err != nil {
+				return err
+			}
+			s.buildAs(val.alias)
+		case CaseExpr:
+			if err := s.buildCase(val); err != nil {
+				return err
+			}
+			s.buildAs(val.alias)
+		case WindowExpr:
+			if err := s.buildWindowExpr(val); err != nil {
+				return err
+			}
+			s.buildAs(val.alias)
 		default:
 			return errs.NewErrUnsupportedSelectable(c)
 		}
@@ -222,6 +309,12 @@ func (s *Selector[T]) Having(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// OrderBy 设置 order by 子句，排序项按照传入的顺序生效
+func (s *Selector[T]) OrderBy(obs ...OrderBy) *Selector[T] {
+	s.orderBy = obs
+	return s
+}
+
 func (s *Selector[T]) Offset(offset int) *Selector[T] {
 	s.offset = offset
 	return s
@@ -232,6 +325,11 @@ func (s *Selector[T]) Limit(limit int) *Selector[T] {
 	return s
 }
 
+// nested 带有 ORDER BY，LIMIT 或者 OFFSET 的查询作为集合操作的操作数的时候需要括号
+func (s *Selector[T]) nested() bool {
+	return len(s.orderBy) > 0 || s.limit > 0 || s.offset > 0
+}
+
 func (s *Selector[T]) AsSubquery(alias string) Subquery {
 	//panic("implement me")
 	//var err error
@@ -245,35 +343,70 @@ func (s *Selector[T]) AsSubquery(alias string) Subquery {
 		return Subquery{error: err}
 	}
 	//s.columns
-	return Subquery{s: (*Selector[any])(s), alias: alias, entity: new(T), columns: s.columns}
+	return Subquery{s: s, alias: alias, entity: new(T), columns: s.columns}
+}
+
+func (s *Selector[T]) Union(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).Union(q)
+}
+
+func (s *Selector[T]) UnionAll(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).UnionAll(q)
+}
+
+func (s *Selector[T]) Intersect(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).Intersect(q)
+}
+
+func (s *Selector[T]) IntersectAll(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).IntersectAll(q)
+}
+
+func (s *Selector[T]) Except(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).Except(q)
+}
+
+func (s *Selector[T]) ExceptAll(q SetOperand) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s.core, s).ExceptAll(q)
 }
 
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
-	res := get[T](ctx, s.core, s.sess, &QueryContext{
-		Builder: s,
-		Type:    "SELECT",
-	})
+	res := get[T](ctx, s.core, s.sess, newQueryContext[T](s.core, "SELECT", s))
 	if res.Result != nil {
 		return res.Result.(*T), res.Err
 	}
 	return nil, res.Err
 }
 
+func (s *Selector[T]) projection() (core, session, *QueryContext) {
+	return s.core, s.sess, newQueryContext[T](s.core, "SELECT", s)
+}
+
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	var db sql.DB
-	q, err := s.Build()
-	if err != nil {
-		return nil, err
-	}
-	rows, err := db.QueryContext(ctx, q.SQL, q.Args...)
-	if err != nil {
-		return nil, err
+	res := getMulti[T](ctx, s.core, s.sess, newQueryContext[T](s.core, "SELECT", s))
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
 	}
+	return nil, res.Err
+}
 
-	for rows.Next() {
-		// 在这里构造 []*T
+// Clone 复制一个 Selector，两者之后的修改互不影响
+// 例如可以先构造一个带有租户条件的 Selector，再分别添加不同的条件
+func (s *Selector[T]) Clone() *Selector[T] {
+	return &Selector[T]{
+		builder:  s.builder.clone(),
+		table:    s.table,
+		where:    append([]Predicate(nil), s.where...),
+		having:   append([]Predicate(nil), s.having...),
+		columns:  append([]Selectable(nil), s.columns...),
+		groupBy:  append([]Column(nil), s.groupBy...),
+		orderBy:  append([]OrderBy(nil), s.orderBy...),
+		offset:   s.offset,
+		limit:    s.limit,
+		sess:     s.sess,
+		distinct: s.distinct,
+		ctes:     append([]CTE(nil), s.ctes...),
 	}
-	panic("implement me")
 }
 
 func NewSelector[T any](sess session) *Selector[T] {
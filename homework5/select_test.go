@@ -3,12 +3,16 @@ package orm
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"exercise/geektime/homework5/version1/internal/errs"
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -192,6 +196,100 @@ func TestSelector_OffsetLimit(t *testing.T) {
 	}
 }
 
+func TestSelector_OrderBy(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	sqliteDB, err := OpenDB(mockdb, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "column",
+			q:    NewSelector[TestModel](db).OrderBy(Asc(C("Age"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC;",
+			},
+		},
+		{
+			name: "multiple",
+			q:    NewSelector[TestModel](db).OrderBy(Asc(C("Age")), Desc(C("Id"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC,`id` DESC;",
+			},
+		},
+		{
+			// 排序项忽略别名
+			name: "aggregate",
+			q: NewSelector[TestModel](db).GroupBy(C("FirstName")).
+				OrderBy(Desc(Avg("Age").As("avg_age"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `first_name` ORDER BY AVG(`age`) DESC;",
+			},
+		},
+		{
+			name: "raw expression",
+			q:    NewSelector[TestModel](db).OrderBy(Asc(Raw("FIELD(`id`, ?, ?)", 3, 1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY FIELD(`id`, ?, ?) ASC;",
+				Args: []any{3, 1},
+			},
+		},
+		{
+			name: "with limit offset",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				OrderBy(Desc(C("Id"))).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `id` DESC LIMIT ? OFFSET ?;",
+				Args: []any{18, 10, 20},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).OrderBy(Asc(C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// MySQL 不支持 NULLS FIRST，用 IS NULL 模拟
+			name: "mysql nulls first",
+			q:    NewSelector[TestModel](db).OrderBy(Asc(C("LastName")).NullsFirst()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` IS NULL DESC,`last_name` ASC;",
+			},
+		},
+		{
+			name: "mysql nulls last",
+			q:    NewSelector[TestModel](db).OrderBy(Desc(C("LastName")).NullsLast()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` IS NULL ASC,`last_name` DESC;",
+			},
+		},
+		{
+			name: "sqlite nulls first",
+			q:    NewSelector[TestModel](sqliteDB).OrderBy(Asc(C("LastName")).NullsFirst()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` ASC NULLS FIRST;",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
 func TestSelector_Having(t *testing.T) {
 	//db := memoryDB(t)
 	mockdb, _, err := sqlmock.New()
@@ -268,6 +366,21 @@ func TestSelector_GroupBy(t *testing.T) {
 	require.NoError(t, err)
 	db, err := OpenDB(mockdb)
 	require.NoError(t, err)
+
+	type Order struct {
+		Id        int
+		UsingCol1 string
+		UsingCol2 string
+	}
+
+	type OrderDetail struct {
+		OrderId int
+		ItemId  int
+
+		UsingCol1 string
+		UsingCol2 string
+	}
+
 	testCases := []struct {
 		name      string
 		q         QueryBuilder
@@ -304,6 +417,50 @@ func TestSelector_GroupBy(t *testing.T) {
 			q:       NewSelector[TestModel](db).GroupBy(C("Invalid")),
 			wantErr: errs.NewErrUnknownField("Invalid"),
 		},
+		{
+			// JOIN 的时候列和聚合函数都要带上表
+			name: "join",
+			q: func() QueryBuilder {
+				t1 := TableOf(&Order{}).As("t1")
+				t2 := TableOf(&OrderDetail{}).As("t2")
+				return NewSelector[Order](db).
+					Select(t1.C("UsingCol1").As("col"), t2.Count("ItemId").As("cnt"),
+						t2.Sum("OrderId"), t2.CountDistinct("UsingCol2")).
+					From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("OrderId")))).
+					GroupBy(t1.C("UsingCol1")).
+					Having(t2.Avg("ItemId").As("avg_item").GT(10), t1.Max("Id").LT(100))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `t1`.`using_col1` AS `col`,COUNT(`t2`.`item_id`) AS `cnt`,SUM(`t2`.`order_id`),COUNT(DISTINCT `t2`.`using_col2`)" +
+					" FROM (`order` AS `t1` JOIN `order_detail` AS `t2` ON `t1`.`id` = `t2`.`order_id`)" +
+					" GROUP BY `t1`.`using_col1` HAVING (AVG(`t2`.`item_id`) > ?) AND (MAX(`t1`.`id`) < ?);",
+				Args: []any{10, 100},
+			},
+		},
+		{
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId"), C("ItemId")).AsSubquery("sub")
+				return NewSelector[OrderDetail](db).
+					Select(sub.C("OrderId"), sub.Count("ItemId"), sub.Min("ItemId").As("min_item")).
+					From(sub).GroupBy(sub.C("OrderId")).Having(sub.Sum("ItemId").GT(3))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `sub`.`order_id`,COUNT(`sub`.`item_id`),MIN(`sub`.`item_id`) AS `min_item`" +
+					" FROM (SELECT `order_id`,`item_id` FROM `order_detail`) AS `sub`" +
+					" GROUP BY `sub`.`order_id` HAVING SUM(`sub`.`item_id`) > ?;",
+				Args: []any{3},
+			},
+		},
+		{
+			// 子查询里面没有的列
+			name: "invalid subquery column",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).AsSubquery("sub")
+				return NewSelector[OrderDetail](db).Select(sub.Max("ItemId")).From(sub)
+			}(),
+			wantErr: errs.NewErrUnknownField("ItemId"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -356,6 +513,28 @@ func TestSelector_Select(t *testing.T) {
 				SQL: "SELECT AVG(`age`) FROM `test_model`;",
 			},
 		},
+		{
+			name: "distinct",
+			q:    NewSelector[TestModel](db).Select(C("FirstName"), C("Age")).Distinct(),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT `first_name`,`age` FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct aggregate",
+			q: NewSelector[TestModel](db).
+				Select(CountDistinct("FirstName").As("cnt"), SumDistinct("Age"), AvgDistinct("Age")).
+				Having(CountDistinct("FirstName").GT(1)).GroupBy(C("LastName")),
+			wantQuery: &Query{
+				SQL:  "SELECT COUNT(DISTINCT `first_name`) AS `cnt`,SUM(DISTINCT `age`),AVG(DISTINCT `age`) FROM `test_model` GROUP BY `last_name` HAVING COUNT(DISTINCT `first_name`) > ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "invalid distinct column",
+			q:       NewSelector[TestModel](db).Select(CountDistinct("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
 		{
 			name: "raw expression",
 			q:    NewSelector[TestModel](db).Select(Raw("COUNT(DISTINCT `first_name`)")),
@@ -495,6 +674,271 @@ func TestSelector_Build(t *testing.T) {
 	}
 }
 
+func TestSelector_Predicates(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		where     []Predicate
+		having    []Predicate
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:  "comparison",
+			where: []Predicate{C("Id").NEQ(1), C("Age").LTEQ(35), C("Age").GTEQ(18)},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`id` != ?) AND (`age` <= ?)) AND (`age` >= ?);",
+				Args: []any{1, 35, 18},
+			},
+		},
+		{
+			name:  "like",
+			where: []Predicate{C("FirstName").Like("Tom%"), C("LastName").NotLike("%Jerry")},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`first_name` LIKE ?) AND (`last_name` NOT LIKE ?);",
+				Args: []any{"Tom%", "%Jerry"},
+			},
+		},
+		{
+			name:  "between",
+			where: []Predicate{C("Age").Between(18, 35)},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` BETWEEN ? AND ?;",
+				Args: []any{18, 35},
+			},
+		},
+		{
+			name:  "null",
+			where: []Predicate{C("LastName").IsNull(), C("FirstName").IsNotNull()},
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (`last_name` IS NULL) AND (`first_name` IS NOT NULL);",
+			},
+		},
+		{
+			name:  "in",
+			where: []Predicate{C("Id").In(1, 2, 3)},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			// 只传入一个切片会被展开
+			name:  "in slice",
+			where: []Predicate{C("Id").NotIn([]int64{1, 2})},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` NOT IN (?,?);",
+				Args: []any{int64(1), int64(2)},
+			},
+		},
+		{
+			name:  "empty in",
+			where: []Predicate{C("Age").GT(18), C("Id").In()},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` > ?) AND (1 = 0);",
+				Args: []any{18},
+			},
+		},
+		{
+			name:  "empty not in",
+			where: []Predicate{C("Id").NotIn([]int{})},
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE 1 = 1;",
+			},
+		},
+		{
+			name:  "math",
+			where: []Predicate{C("Age").Add(1).Between(C("Id"), 20)},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` + ?) BETWEEN `id` AND ?;",
+				Args: []any{1, 20},
+			},
+		},
+		{
+			name:   "aggregate",
+			having: []Predicate{Avg("Age").In(18, 20), Count("Id").GTEQ(10), Max("Age").IsNotNull()},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING ((AVG(`age`) IN (?,?)) AND (COUNT(`id`) >= ?)) AND (MAX(`age`) IS NOT NULL);",
+				Args: []any{18, 20, 10},
+			},
+		},
+		{
+			name:    "invalid column",
+			where:   []Predicate{C("Invalid").Between(1, 2)},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSelector[TestModel](db).Where(tc.where...)
+			if len(tc.having) > 0 {
+				s = s.GroupBy(C("Age")).Having(tc.having...)
+			}
+			q, err := s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_MathExpr(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "multi",
+			q:    NewSelector[TestModel](db).Where(C("Age").Multi(2).GT(30)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` * ?) > ?;",
+				Args: []any{2, 30},
+			},
+		},
+		{
+			name: "column to column",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").Sub(C("Id")).Div(C("Age").Add(1)).LTEQ(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`age` - `id`) / (`age` + ?)) <= ?;",
+				Args: []any{1, 1},
+			},
+		},
+		{
+			name: "select with alias",
+			q: NewSelector[TestModel](db).
+				Select(C("Id"), C("Age").Mod(10).As("age_mod"), Neg(C("Age")).As("neg_age")).
+				OrderBy(Desc(C("Age").Add(C("Id")))),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,`age` % ? AS `age_mod`,-`age` AS `neg_age` FROM `test_model` ORDER BY `age` + `id` DESC;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "negative expression",
+			q:    NewSelector[TestModel](db).Where(C("Age").Add(1).Neg().LT(0)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (-(`age` + ?)) < ?;",
+				Args: []any{1, 0},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Where(C("Age").Sub(C("Invalid")).GT(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_Func(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "coalesce",
+			q: NewSelector[TestModel](db).
+				Select(Coalesce(C("LastName"), C("FirstName"), "unknown").As("name")).
+				Where(Lower(C("FirstName")).EQ("tom")),
+			wantQuery: &Query{
+				SQL:  "SELECT COALESCE(`last_name`,`first_name`,?) AS `name` FROM `test_model` WHERE LOWER(`first_name`) = ?;",
+				Args: []any{"unknown", "tom"},
+			},
+		},
+		{
+			name: "custom function",
+			q: NewSelector[TestModel](db).
+				Where(Fn("LENGTH", Upper(C("FirstName"))).GT(3)).
+				OrderBy(Asc(Fn("ABS", C("Age")))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE LENGTH(UPPER(`first_name`)) > ? ORDER BY ABS(`age`) ASC;",
+				Args: []any{3},
+			},
+		},
+		{
+			name: "case when",
+			q: NewSelector[TestModel](db).
+				Select(C("Id"), Case().When(C("Age").LT(18), "child").
+					When(C("Age").Between(18, 60), "adult").
+					Else("senior").As("stage")),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,CASE WHEN `age` < ? THEN ? WHEN `age` BETWEEN ? AND ? THEN ? ELSE ? END AS `stage` FROM `test_model`;",
+				Args: []any{18, "child", 18, 60, "adult", "senior"},
+			},
+		},
+		{
+			name: "case in where",
+			q: NewSelector[TestModel](db).
+				Where(Case().When(C("LastName").IsNull(), C("FirstName")).
+					Else(C("LastName")).EQ("Tom")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE CASE WHEN `last_name` IS NULL THEN `first_name` ELSE `last_name` END = ?;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "not like",
+			q: NewSelector[TestModel](db).
+				Where(Lower(C("FirstName")).NotLike("tom%"),
+					Case().When(C("LastName").IsNull(), C("FirstName")).Else(C("LastName")).NotLike("%jerry")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (LOWER(`first_name`) NOT LIKE ?) AND (CASE WHEN `last_name` IS NULL THEN `first_name` ELSE `last_name` END NOT LIKE ?);",
+				Args: []any{"tom%", "%jerry"},
+			},
+		},
+		{
+			name:    "empty case",
+			q:       NewSelector[TestModel](db).Select(Case().Else(1)),
+			wantErr: errs.ErrEmptyCaseWhen,
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Where(Coalesce(C("Invalid"), 1).EQ(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
 func TestSelector_Get(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
@@ -575,6 +1019,329 @@ func TestSelector_Get(t *testing.T) {
 	}
 }
 
+func TestSelector_GetEmbedded(t *testing.T) {
+	type BaseEntity struct {
+		Id        int64
+		CreatedAt int64
+	}
+	type Address struct {
+		City string
+	}
+	type EmbeddedModel struct {
+		BaseEntity
+		Name    string
+		Address `orm:"prefix=addr_"`
+	}
+
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflectValuer()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, tc.opts...)
+			require.NoError(t, err)
+
+			rows := sqlmock.NewRows([]string{"id", "created_at", "name", "addr_city"})
+			rows.AddRow([]byte("1"), []byte("1000"), []byte("Tom"), []byte("Shanghai"))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`name`,`addr_city` FROM `embedded_model` WHERE `created_at` > ?;")).
+				WithArgs(10).WillReturnRows(rows)
+
+			res, err := NewSelector[EmbeddedModel](db).
+				Select(C("Id"), C("Name"), C("City")).
+				Where(C("CreatedAt").GT(10)).Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, &EmbeddedModel{
+				BaseEntity: BaseEntity{Id: 1, CreatedAt: 1000},
+				Name:       "Tom",
+				Address:    Address{City: "Shanghai"},
+			}, res)
+		})
+	}
+}
+
+// TestSelector_GetAmbiguousEmbedded 两种 valuer 对同名字段的处理必须一致
+func TestSelector_GetAmbiguousEmbedded(t *testing.T) {
+	type Author struct {
+		Name string
+	}
+	type Publisher struct {
+		Name string
+	}
+	type Book struct {
+		Id        int64
+		Author    `orm:"prefix=author_"`
+		Publisher `orm:"prefix=publisher_"`
+	}
+	type NamedBook struct {
+		Id        int64
+		Author    `orm:"prefix=author_"`
+		Publisher `orm:"prefix=publisher_"`
+		Name      string
+	}
+
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflectValuer()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, tc.opts...)
+			require.NoError(t, err)
+
+			_, err = NewSelector[Book](db).Get(context.Background())
+			assert.Equal(t, errs.NewErrAmbiguousField("Name"), err)
+
+			rows := sqlmock.NewRows([]string{"id", "name"})
+			rows.AddRow([]byte("1"), []byte("Go"))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `named_book`;")).WillReturnRows(rows)
+			res, err := NewSelector[NamedBook](db).Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, &NamedBook{Id: 1, Name: "Go"}, res)
+		})
+	}
+}
+
+// upperString 只有指针实现了 sql.Scanner
+type upperString struct {
+	val string
+}
+
+func (u *upperString) Scan(src any) error {
+	if src == nil {
+		u.val = "NULL"
+		return nil
+	}
+	u.val = strings.ToUpper(string(src.([]byte)))
+	return nil
+}
+
+func TestSelector_GetNullable(t *testing.T) {
+	type NullableModel struct {
+		Id       int64
+		Name     *string
+		Age      sql.NullInt64
+		Nickname string      `orm:"null_zero"`
+		Score    *float64    `orm:"null_zero"`
+		Tag      upperString `orm:"null_zero"`
+	}
+
+	name := "Tom"
+	score := 9.5
+	testCases := []struct {
+		name    string
+		cols    []string
+		row     []driver.Value
+		wantVal *NullableModel
+		wantErr string
+	}{
+		{
+			name: "all null",
+			cols: []string{"id", "name", "age", "nickname", "score", "tag"},
+			row:  []driver.Value{[]byte("1"), nil, nil, nil, nil, nil},
+			wantVal: &NullableModel{
+				Id:  1,
+				Tag: upperString{val: "NULL"},
+			},
+		},
+		{
+			name: "not null",
+			cols: []string{"id", "name", "age", "nickname", "score", "tag"},
+			row: []driver.Value{[]byte("1"), []byte("Tom"), []byte("18"),
+				[]byte("tom"), []byte("9.5"), []byte("vip")},
+			wantVal: &NullableModel{
+				Id:       1,
+				Name:     &name,
+				Age:      sql.NullInt64{Int64: 18, Valid: true},
+				Nickname: "tom",
+				Score:    &score,
+				Tag:      upperString{val: "VIP"},
+			},
+		},
+		{
+			// 没有 null_zero 的普通字段读到 NULL 返回错误
+			name:    "null into value",
+			cols:    []string{"id"},
+			row:     []driver.Value{nil},
+			wantErr: "converting NULL to int64 is unsupported",
+		},
+	}
+
+	valuers := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflectValuer()},
+		},
+	}
+
+	for _, v := range valuers {
+		for _, tc := range testCases {
+			t.Run(v.name+" "+tc.name, func(t *testing.T) {
+				mockDB, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				defer func() { _ = mockDB.Close() }()
+				db, err := OpenDB(mockDB, v.opts...)
+				require.NoError(t, err)
+
+				rows := sqlmock.NewRows(tc.cols).AddRow(tc.row...)
+				mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+				res, err := NewSelector[NullableModel](db).Get(context.Background())
+				if tc.wantErr != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), tc.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantVal, res)
+			})
+		}
+	}
+}
+
+func TestSelector_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		query    string
+		mockErr  error
+		mockRows *sqlmock.Rows
+		wantErr  error
+		wantVal  []*TestModel
+	}{
+		{
+			// 查询返回错误
+			name:    "query error",
+			mockErr: errors.New("invalid query"),
+			wantErr: errors.New("invalid query"),
+			query:   "SELECT .*",
+		},
+		{
+			// 没有数据不是错误，返回空切片
+			name:     "no row",
+			query:    "SELECT .*",
+			mockRows: sqlmock.NewRows([]string{"id"}),
+			wantVal:  []*TestModel{},
+		},
+		{
+			name:    "too many column",
+			wantErr: errs.ErrTooManyReturnedColumns,
+			query:   "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name", "extra_column"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"), []byte("nothing"))
+				return res
+			}(),
+		},
+		{
+			name:  "multiple rows",
+			query: "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				res.AddRow([]byte("2"), []byte("Xiao"), []byte("16"), []byte("Hong"))
+				return res
+			}(),
+			wantVal: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Da",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				{
+					Id:        2,
+					FirstName: "Xiao",
+					Age:       16,
+					LastName:  &sql.NullString{String: "Hong", Valid: true},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		exp := mock.ExpectQuery(tc.query)
+		if tc.mockErr != nil {
+			exp.WillReturnError(tc.mockErr)
+		} else {
+			exp.WillReturnRows(tc.mockRows)
+		}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, res)
+		})
+	}
+}
+
+func TestRawQuerier_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "first_name"})
+	rows.AddRow([]byte("1"), []byte("Da"))
+	rows.AddRow([]byte("2"), []byte("Xiao"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ?")).
+		WithArgs(18).WillReturnRows(rows)
+
+	res, err := RawQuery[TestModel](db,
+		"SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ?", 18).
+		GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Da"},
+		{Id: 2, FirstName: "Xiao"},
+	}, res)
+}
+
 // 在 orm 目录下执行
 // go test -bench=BenchmarkQuerier_Get -benchmem -benchtime=10000x
 // 我的输出结果
@@ -728,6 +1495,68 @@ func TestSelector_Subquery(t *testing.T) {
 }
 
 // Join 和 Subquery 混合使用
+// TestSelector_SubqueryReuse 同一个子查询用在多个查询里面，占位符的编号都是对的
+func TestSelector_SubqueryReuse(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	inner := NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18))
+	sub := inner.AsSubquery("sub")
+
+	q, err := NewSelector[TestModel](db).Where(C("FirstName").EQ("Tom"), C("Id").InQuery(sub)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `SELECT * FROM "test_model" WHERE ("first_name" = $1) AND ("id" IN (SELECT "id" FROM "test_model" WHERE "age" > $2));`,
+		Args: []any{"Tom", 18},
+	}, q)
+
+	q, err = NewSelector[TestModel](db).Where(C("Id").InQuery(sub)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `SELECT * FROM "test_model" WHERE "id" IN (SELECT "id" FROM "test_model" WHERE "age" > $1);`,
+		Args: []any{18},
+	}, q)
+
+	q, err = inner.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `SELECT "id" FROM "test_model" WHERE "age" > $1;`,
+		Args: []any{18},
+	}, q)
+}
+
+func TestSelector_ConcurrentBuild(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	sub := NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).AsSubquery("sub")
+	s := NewSelector[TestModel](db).Where(C("FirstName").EQ("Tom"), C("Id").InQuery(sub))
+	want := &Query{
+		SQL:  `SELECT * FROM "test_model" WHERE ("first_name" = $1) AND ("id" IN (SELECT "id" FROM "test_model" WHERE "age" > $2));`,
+		Args: []any{"Tom", 18},
+	}
+
+	var wg sync.WaitGroup
+	qs := make([]*Query, 4)
+	buildErrs := make([]error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			qs[i], buildErrs[i] = s.Build()
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, buildErrs[i])
+		assert.Equal(t, want, qs[i])
+	}
+}
+
 func TestSelector_SubqueryAndJoin(t *testing.T) {
 	//db := memoryDB(t)
 	mockdb, _, err := sqlmock.New()
@@ -855,3 +1684,36 @@ func TestSelector_SubqueryAndJoin(t *testing.T) {
 		})
 	}
 }
+
+func TestSelector_Clone(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	base := NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").GT(18))
+	// 重复构造的结果是一样的
+	q1, err := base.Build()
+	require.NoError(t, err)
+	q2, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q2)
+
+	page := base.Clone().OrderBy(Desc(C("Id"))).Limit(10)
+	cnt := base.Clone().Select(Count("Id"))
+	q, err := page.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `SELECT "id","age" FROM "test_model" WHERE "age" > $1 ORDER BY "id" DESC LIMIT $2;`,
+		Args: []any{18, 10},
+	}, q)
+	q, err = cnt.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `SELECT COUNT("id") FROM "test_model" WHERE "age" > $1;`,
+		Args: []any{18},
+	}, q)
+	q, err = base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q)
+}
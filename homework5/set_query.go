@@ -0,0 +1,217 @@
+package orm
+
+import (
+	"context"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"fmt"
+)
+
+// setOperation 集合操作
+type setOperation string
+
+const (
+	setUnion        setOperation = "UNION"
+	setUnionAll     setOperation = "UNION ALL"
+	setIntersect    setOperation = "INTERSECT"
+	setIntersectAll setOperation = "INTERSECT ALL"
+	setExcept       setOperation = "EXCEPT"
+	setExceptAll    setOperation = "EXCEPT ALL"
+)
+
+// SetOperand 可以参与集合操作，也可以作为子查询的查询，
+// 例如 *Selector[T] 和 *SetQuery[T]
+type SetOperand interface {
+	QueryBuilder
+	// buildAt 作为子查询构造，占位符的编号从 offset 之后开始
+	buildAt(offset int) (*Query, error)
+}
+
+var _ SetOperand = &Selector[any]{}
+var _ SetOperand = &SetQuery[any]{}
+
+type setOperand struct {
+	op    setOperation
+	query SetOperand
+}
+
+// SetQuery 用于构造 UNION，INTERSECT 和 EXCEPT 这种集合操作
+// 结果集按照 T 来解析，OrderBy 里面的列也是 T 的字段
+// 所有的查询的列必须一致，这一点依赖于数据库来检查
+type SetQuery[T any] struct {
+	builder
+	first    SetOperand
+	operands []setOperand
+	orderBy  []OrderBy
+	offset   int
+	limit    int
+	sess     session
+}
+
+func newSetQuery[T any](sess session, c core, first SetOperand) *SetQuery[T] {
+	return &SetQuery[T]{
+		sess:  sess,
+		first: first,
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+	}
+}
+
+func (s *SetQuery[T]) with(op setOperation, q SetOperand) *SetQuery[T] {
+	s.operands = append(s.operands, setOperand{op: op, query: q})
+	return s
+}
+
+func (s *SetQuery[T]) Union(q SetOperand) *SetQuery[T] {
+	return s.with(setUnion, q)
+}
+
+func (s *SetQuery[T]) UnionAll(q SetOperand) *SetQuery[T] {
+	return s.with(setUnionAll, q)
+}
+
+func (s *SetQuery[T]) Intersect(q SetOperand) *SetQuery[T] {
+	return s.with(setIntersect, q)
+}
+
+func (s *SetQuery[T]) IntersectAll(q SetOperand) *SetQuery[T] {
+	return s.with(setIntersectAll, q)
+}
+
+func (s *SetQuery[T]) Except(q SetOperand) *SetQuery[T] {
+	return s.with(setExcept, q)
+}
+
+func (s *SetQuery[T]) ExceptAll(q SetOperand) *SetQuery[T] {
+	return s.with(setExceptAll, q)
+}
+
+// OrderBy 对整个结果集排序
+func (s *SetQuery[T]) OrderBy(obs ...OrderBy) *SetQuery[T] {
+	s.orderBy = obs
+	return s
+}
+
+func (s *SetQuery[T]) Offset(offset int) *SetQuery[T] {
+	s.offset = offset
+	return s
+}
+
+func (s *SetQuery[T]) Limit(limit int) *SetQuery[T] {
+	s.limit = limit
+	return s
+}
+
+func (s *SetQuery[T]) Build() (*Query, error) {
+	return s.buildAt(0)
+}
+
+// buildAt 在副本上构造，不修改 SetQuery 本身
+func (s *SetQuery[T]) buildAt(offset int) (*Query, error) {
+	cp := *s
+	cp.builder = s.builder.clone()
+	cp.argOffset = offset
+	return cp.build()
+}
+
+func (s *SetQuery[T]) build() (*Query, error) {
+	var err error
+	if s.model == nil {
+		s.model, err = s.r.Get(new(T))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.buildOperand(s.first, 0); err != nil {
+		return nil, err
+	}
+	for i, o := range s.operands {
+		if !s.dialect.supportSetOperation(o.op) {
+			return nil, errs.NewErrUnsupportedByDialect(string(o.op))
+		}
+		s.sb.WriteByte(' ')
+		s.sb.WriteString(string(o.op))
+		s.sb.WriteByte(' ')
+		if err = s.buildOperand(o.query, i+1); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.orderBy) > 0 {
+		if err = s.buildOrderBy(s.orderBy); err != nil {
+			return nil, err
+		}
+	}
+	if s.limit > 0 {
+		s.sb.WriteString(" LIMIT ")
+		s.parameter(s.limit)
+	}
+	if s.offset > 0 {
+		s.sb.WriteString(" OFFSET ")
+		s.parameter(s.offset)
+	}
+	s.sb.WriteString(";")
+	return &Query{
+		SQL:  s.sb.String(),
+		Args: s.args,
+	}, nil
+}
+
+// buildOperand 嵌套的集合操作需要加上括号，
+// 例如 a UNION (b INTERSECT c)，否则优先级就不对了。
+// 带有 ORDER BY 或者 LIMIT 的查询也一样，否则它们会作用在整个结果集上。
+// 不支持括号的方言改写成派生表，例如 SELECT * FROM (b INTERSECT c) AS _set1
+func (s *SetQuery[T]) buildOperand(q SetOperand, idx int) error {
+	n, ok := q.(interface{ nested() bool })
+	if !ok || !n.nested() {
+		return s.buildSubquery(q)
+	}
+	if s.dialect.supportNestedSetOperation() {
+		s.sb.WriteByte('(')
+		if err := s.buildSubquery(q); err != nil {
+			return err
+		}
+		s.sb.WriteByte(')')
+		return nil
+	}
+	s.sb.WriteString("SELECT * FROM (")
+	if err := s.buildSubquery(q); err != nil {
+		return err
+	}
+	s.sb.WriteString(") AS ")
+	s.quote(fmt.Sprintf("_set%d", idx))
+	return nil
+}
+
+// nested 作为集合操作的操作数的时候总是需要括号
+func (s *SetQuery[T]) nested() bool {
+	return true
+}
+
+// AsSubquery 作为子查询，例如用在 FROM 里面
+func (s *SetQuery[T]) AsSubquery(alias string) Subquery {
+	return Subquery{s: s, alias: alias, entity: new(T)}
+}
+
+func (s *SetQuery[T]) Get(ctx context.Context) (*T, error) {
+	res := get[T](ctx, s.core, s.sess, newQueryContext[T](s.core, "SELECT", s))
+	if res.Result != nil {
+		return res.Result.(*T), res.Err
+	}
+	return nil, res.Err
+}
+
+func (s *SetQuery[T]) projection() (core, session, *QueryContext) {
+	return s.core, s.sess, newQueryContext[T](s.core, "SELECT", s)
+}
+
+func (s *SetQuery[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	res := getMulti[T](ctx, s.core, s.sess, newQueryContext[T](s.core, "SELECT", s))
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
+}
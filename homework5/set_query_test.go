@@ -0,0 +1,191 @@
+package orm
+
+import (
+	"context"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestSetQuery_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+	sqliteDB, err := OpenDB(mockdb, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+	pgDB, err := OpenDB(mockdb, DBWithDialect(Postgres))
+	require.NoError(t, err)
+
+	type ArchivedModel struct {
+		Id        int64
+		FirstName string
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "union",
+			q: NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18)).
+				Union(NewSelector[ArchivedModel](db).Select(C("Id"), C("FirstName"))),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ? UNION SELECT `id`,`first_name` FROM `archived_model`;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "union all with order and limit",
+			q: NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).
+				UnionAll(NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").LT(10))).
+				OrderBy(Desc(C("Id"))).Limit(10).Offset(5),
+			wantQuery: &Query{
+				SQL:  "SELECT `id` FROM `test_model` WHERE `age` > ? UNION ALL SELECT `id` FROM `test_model` WHERE `age` < ? ORDER BY `id` DESC LIMIT ? OFFSET ?;",
+				Args: []any{18, 10, 10, 5},
+			},
+		},
+		{
+			// 占位符的编号在所有的查询之间连续
+			name: "postgres placeholders",
+			q: NewSelector[TestModel](pgDB).Select(C("Id")).Where(C("Age").GT(18)).
+				Except(NewSelector[TestModel](pgDB).Select(C("Id")).Where(C("Age").GT(60))).
+				Limit(10),
+			wantQuery: &Query{
+				SQL:  `SELECT "id" FROM "test_model" WHERE "age" > $1 EXCEPT SELECT "id" FROM "test_model" WHERE "age" > $2 LIMIT $3;`,
+				Args: []any{18, 60, 10},
+			},
+		},
+		{
+			name: "nested",
+			q: NewSelector[TestModel](pgDB).Select(C("Id")).
+				Union(NewSelector[TestModel](pgDB).Select(C("Id")).Where(C("Age").GT(18)).
+					IntersectAll(NewSelector[TestModel](pgDB).Select(C("Id")).Where(C("Age").LT(60)))),
+			wantQuery: &Query{
+				SQL:  `SELECT "id" FROM "test_model" UNION (SELECT "id" FROM "test_model" WHERE "age" > $1 INTERSECT ALL SELECT "id" FROM "test_model" WHERE "age" < $2);`,
+				Args: []any{18, 60},
+			},
+		},
+		{
+			// SQLite 不支持带括号的操作数，改写成派生表
+			name: "sqlite nested",
+			q: NewSelector[TestModel](sqliteDB).Select(C("Id")).
+				Union(NewSelector[TestModel](sqliteDB).Select(C("Id")).Where(C("Age").GT(18)).
+					Except(NewSelector[TestModel](sqliteDB).Select(C("Id")).Where(C("Age").LT(60)))),
+			wantQuery: &Query{
+				SQL:  "SELECT `id` FROM `test_model` UNION SELECT * FROM (SELECT `id` FROM `test_model` WHERE `age` > ? EXCEPT SELECT `id` FROM `test_model` WHERE `age` < ?) AS `_set1`;",
+				Args: []any{18, 60},
+			},
+		},
+		{
+			// 操作数自己的 LIMIT 不能作用到整个结果集上
+			name: "operand with limit",
+			q: NewSelector[TestModel](pgDB).Select(C("Id")).OrderBy(Desc(C("Age"))).Limit(10).
+				Union(NewSelector[TestModel](pgDB).Select(C("Id")).Where(C("Age").GT(18))),
+			wantQuery: &Query{
+				SQL:  `(SELECT "id" FROM "test_model" ORDER BY "age" DESC LIMIT $1) UNION SELECT "id" FROM "test_model" WHERE "age" > $2;`,
+				Args: []any{10, 18},
+			},
+		},
+		{
+			name: "mysql operand with limit",
+			q: NewSelector[TestModel](db).Select(C("Id")).
+				UnionAll(NewSelector[ArchivedModel](db).Select(C("Id")).Limit(10)).
+				Limit(20),
+			wantQuery: &Query{
+				SQL:  "SELECT `id` FROM `test_model` UNION ALL SELECT * FROM (SELECT `id` FROM `archived_model` LIMIT ?) AS `_set1` LIMIT ?;",
+				Args: []any{10, 20},
+			},
+		},
+		{
+			name: "as subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").GT(18)).
+					Union(NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").LT(10))).
+					AsSubquery("sub")
+				return NewSelector[TestModel](db).Select(sub.C("Id")).From(sub).Where(C("Id").GT(100))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT `sub`.`id` FROM (SELECT `id`,`age` FROM `test_model` WHERE `age` > ? UNION SELECT `id`,`age` FROM `test_model` WHERE `age` < ?) AS `sub` WHERE `id` > ?;",
+				Args: []any{18, 10, 100},
+			},
+		},
+		{
+			name: "in subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).
+					Union(NewSelector[ArchivedModel](db).Select(C("Id"))).AsSubquery("sub")
+				return NewSelector[TestModel](db).Where(C("Id").InQuery(sub))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (SELECT `id` FROM `test_model` WHERE `age` > ? UNION SELECT `id` FROM `archived_model`);",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "mysql intersect",
+			q: NewSelector[TestModel](db).Select(C("Id")).
+				Intersect(NewSelector[ArchivedModel](db).Select(C("Id"))),
+			wantErr: errs.NewErrUnsupportedByDialect("INTERSECT"),
+		},
+		{
+			name: "sqlite except",
+			q: NewSelector[TestModel](sqliteDB).Select(C("Id")).
+				Except(NewSelector[ArchivedModel](sqliteDB).Select(C("Id"))),
+			wantQuery: &Query{
+				SQL: "SELECT `id` FROM `test_model` EXCEPT SELECT `id` FROM `archived_model`;",
+			},
+		},
+		{
+			name: "sqlite except all",
+			q: NewSelector[TestModel](sqliteDB).Select(C("Id")).
+				ExceptAll(NewSelector[ArchivedModel](sqliteDB).Select(C("Id"))),
+			wantErr: errs.NewErrUnsupportedByDialect("EXCEPT ALL"),
+		},
+		{
+			name: "invalid column",
+			q: NewSelector[TestModel](db).Select(C("Id")).
+				Union(NewSelector[ArchivedModel](db).Select(C("Age"))),
+			wantErr: errs.NewErrUnknownField("Age"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSetQuery_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"id", "first_name"})
+	rows.AddRow([]byte("1"), []byte("Tom"))
+	rows.AddRow([]byte("2"), []byte("Jerry"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ? UNION SELECT `id`,`first_name` FROM `test_model` WHERE `age` < ?;")).
+		WithArgs(60, 18).WillReturnRows(rows)
+
+	res, err := NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(60)).
+		Union(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").LT(18))).
+		GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 1, FirstName: "Tom"},
+		{Id: 2, FirstName: "Jerry"},
+	}, res)
+}
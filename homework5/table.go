@@ -144,7 +144,7 @@ func (j *JoinBuilder) Using(cs ...string) Join {
 }
 
 type Subquery struct {
-	s       *Selector[any]
+	s       SetOperand
 	entity  any
 	alias   string
 	error   error
@@ -182,16 +182,10 @@ func (s Subquery) RightJoin(target TableReference) *JoinBuilder {
 }
 
 func (s Subquery) C(name string) Column {
-	//fmt.Println(s.s.model)
-	//for _, values := range s.columns {
-	//	//fmt.Println(index, values)
-	//	//fmt.Println("TAGMMM", name, values.target(), values.fieldName(), values.selectedAlias())
-	//}
-
-	//有指明的话
-	res := Column{table: TableOf(s.entity).As(s.alias).Add(s.columns...), name: name}
-	//fmt.Println(res)
-	//res.Makealias()
-
-	return res
+	return Column{table: s.asTable(), name: name}
+}
+
+// asTable 子查询的列按照 entity 来解析，并且只能使用子查询里面指定的列
+func (s Subquery) asTable() Table {
+	return TableOf(s.entity).As(s.alias).Add(s.columns...)
 }
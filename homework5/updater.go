@@ -3,6 +3,7 @@ package orm
 import (
 	"context"
 	"exercise/geektime/homework5/version1/internal/errs"
+	"exercise/geektime/homework5/version1/internal/valuer"
 )
 
 type Updater[T any] struct {
@@ -25,6 +26,8 @@ func NewUpdater[T any](sess session) *Updater[T] {
 	}
 }
 
+// Update 指定更新的实例，Set 里面的 Column 会从实例中取值
+// 如果没有调用 Where，那么会使用实例的主键作为条件
 func (u *Updater[T]) Update(t *T) *Updater[T] {
 	u.val = t
 	return u
@@ -35,14 +38,34 @@ func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
 	return u
 }
 
+// Clone 复制一个 Updater，两者之后的修改互不影响
+// Update 传入的对象本身是共享的
+func (u *Updater[T]) Clone() *Updater[T] {
+	return &Updater[T]{
+		builder: u.builder.clone(),
+		assigns: append([]Assignable(nil), u.assigns...),
+		val:     u.val,
+		where:   append([]Predicate(nil), u.where...),
+		sess:    u.sess,
+	}
+}
+
+// Build 在副本上构造，不修改 Updater 本身，所以可以重复调用，也可以并发调用
 func (u *Updater[T]) Build() (*Query, error) {
+	cp := *u
+	cp.builder = u.builder.clone()
+	return cp.build()
+}
+
+func (u *Updater[T]) build() (*Query, error) {
 	if len(u.assigns) == 0 {
 		return nil, errs.ErrNoUpdatedColumns
 	}
-	if u.val == nil {
-		u.val = new(T)
+	entity := u.val
+	if entity == nil {
+		entity = new(T)
 	}
-	model, err := u.r.Get(u.val)
+	model, err := u.r.Get(entity)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +73,7 @@ func (u *Updater[T]) Build() (*Query, error) {
 	u.sb.WriteString("UPDATE ")
 	u.quote(model.TableName)
 	u.sb.WriteString(" SET ")
-	val := u.valCreator(u.val, model)
+	val := u.valCreator(entity, model)
 	for i, a := range u.assigns {
 		if i > 0 {
 			u.sb.WriteByte(',')
@@ -60,12 +83,12 @@ func (u *Updater[T]) Build() (*Query, error) {
 			if err = u.buildColumn(assign.table, assign.name); err != nil {
 				return nil, err
 			}
-			u.sb.WriteString("=?")
+			u.sb.WriteByte('=')
 			arg, err := val.Field(assign.name)
 			if err != nil {
 				return nil, err
 			}
-			u.addArgs(arg)
+			u.parameter(arg)
 		case Assignment:
 			if err = u.buildAssignment(assign); err != nil {
 				return nil, err
@@ -74,9 +97,17 @@ func (u *Updater[T]) Build() (*Query, error) {
 			return nil, errs.NewErrUnsupportedAssignableType(a)
 		}
 	}
-	if len(u.where) > 0 {
+	where := u.where
+	if len(where) == 0 && u.val != nil {
+		// 没有指定条件，但是传入了实例，就按照主键更新
+		where, err = u.primaryKeyWhere(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(where) > 0 {
 		u.sb.WriteString(" WHERE ")
-		if err = u.buildPredicates(u.where); err != nil {
+		if err = u.buildPredicates(where); err != nil {
 			return nil, err
 		}
 	}
@@ -87,12 +118,19 @@ func (u *Updater[T]) Build() (*Query, error) {
 	}, nil
 }
 
-func (u *Updater[T]) buildAssignment(assign Assignment) error {
-	if err := u.buildColumn(nil, assign.column); err != nil {
-		return err
+func (u *Updater[T]) primaryKeyWhere(val valuer.Value) ([]Predicate, error) {
+	if len(u.model.PrimaryKeys) == 0 {
+		return nil, errs.ErrNoPrimaryKey
 	}
-	u.sb.WriteByte('=')
-	return u.buildExpression(assign.val)
+	ps := make([]Predicate, 0, len(u.model.PrimaryKeys))
+	for _, pk := range u.model.PrimaryKeys {
+		arg, err := val.Field(pk.GoName)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, C(pk.GoName).EQ(arg))
+	}
+	return ps, nil
 }
 
 func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
@@ -101,10 +139,5 @@ func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
 }
 
 func (u *Updater[T]) Exec(ctx context.Context) Result {
-	q, err := u.Build()
-	if err != nil {
-		return Result{err: err}
-	}
-	res, err := u.sess.execContext(ctx, q.SQL, q.Args...)
-	return Result{err: err, res: res}
+	return exec(ctx, u.sess, u.core, newQueryContext[T](u.core, "UPDATE", u))
 }
@@ -0,0 +1,257 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestUpdater_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type KeyModel struct {
+		TenantId int64 `orm:"pk"`
+		Id       int64 `orm:"pk,auto_increment"`
+		Name     string
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "no columns",
+			q:       NewUpdater[TestModel](db),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name: "where",
+			q: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+				Set(C("Age")).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=? WHERE `id` = ?;",
+				Args: []any{int8(18), 1},
+			},
+		},
+		{
+			// 列和列之间的运算
+			name: "math assignment",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Sub(C("Id")).Multi(2)),
+					Assign("Id", Neg(C("Id")))).
+				Where(C("Id").Mod(2).EQ(0)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=(`age` - `id`) * ?,`id`=-`id` WHERE (`id` % ?) = ?;",
+				Args: []any{2, 2, 0},
+			},
+		},
+		{
+			name: "function assignment",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", Upper(C("FirstName"))),
+					Assign("Age", Case().When(C("Age").LT(0), 0).Else(C("Age")))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=UPPER(`first_name`),`age`=CASE WHEN `age` < ? THEN ? ELSE `age` END;",
+				Args: []any{0, 0},
+			},
+		},
+		{
+			// 没有主键，也没有 WHERE，不能更新整张表
+			name:    "no primary key",
+			q:       NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).Set(C("Age")),
+			wantErr: errs.ErrNoPrimaryKey,
+		},
+		{
+			// 默认使用主键作为条件
+			name: "primary key",
+			q: NewUpdater[KeyModel](db).
+				Update(&KeyModel{TenantId: 3, Id: 12, Name: "Tom"}).Set(C("Name")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `key_model` SET `name`=? WHERE (`tenant_id` = ?) AND (`id` = ?);",
+				Args: []any{"Tom", int64(3), int64(12)},
+			},
+		},
+		{
+			// 指定了 WHERE 就不会使用主键
+			name: "where over primary key",
+			q: NewUpdater[KeyModel](db).
+				Update(&KeyModel{TenantId: 3, Id: 12, Name: "Tom"}).
+				Set(C("Name")).Where(C("Name").EQ("Jerry")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `key_model` SET `name`=? WHERE `name` = ?;",
+				Args: []any{"Tom", "Jerry"},
+			},
+		},
+		{
+			// 没有传入实例，不会使用主键
+			name: "assignment without entity",
+			q:    NewUpdater[KeyModel](db).Set(Assign("Name", "Tom")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `key_model` SET `name`=?;",
+				Args: []any{"Tom"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestUpdater_Clone(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	base := NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).Set(C("Age")).Where(C("Id").EQ(1))
+	q1, err := base.Build()
+	require.NoError(t, err)
+	q2, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "UPDATE `test_model` SET `age`=? WHERE `id` = ?;",
+		Args: []any{int8(18), 1},
+	}, q2)
+	assert.Equal(t, q1, q2)
+
+	q, err := base.Clone().Where(C("Id").EQ(2)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, []any{int8(18), 2}, q.Args)
+	q, err = base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q)
+}
+
+func TestUpdater_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var types []string
+	var tables []string
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			types = append(types, qc.Type)
+			tables = append(tables, qc.Model.TableName)
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_model` SET `age`=? WHERE `id` = ?")).
+		WithArgs(int8(18), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_model` SET `age`=? WHERE `id` = ?")).
+		WithArgs(int8(18), 2).WillReturnError(errors.New("exec error"))
+
+	res := NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+		Set(C("Age")).Where(C("Id").EQ(1)).Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	res = NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+		Set(C("Age")).Where(C("Id").EQ(2)).Exec(context.Background())
+	assert.Equal(t, errors.New("exec error"), res.Err())
+
+	assert.Equal(t, []string{"UPDATE", "UPDATE"}, types)
+	assert.Equal(t, []string{"test_model", "test_model"}, tables)
+}
+
+func TestUpdater_JSON(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type Attrs struct {
+		Color string `json:"color"`
+	}
+	type Product struct {
+		Id    int64
+		Attrs Attrs `orm:"json"`
+		Tags  JsonColumn[[]string]
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// Assign 的值和 Update 传入实例的时候一样编码
+			name: "assign",
+			q: NewUpdater[Product](db).
+				Set(Assign("Attrs", map[string]string{"color": "red"})).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `product` SET `attrs`=? WHERE `id` = ?;",
+				Args: []any{`{"color":"red"}`, 1},
+			},
+		},
+		{
+			name: "assign struct",
+			q:    NewUpdater[Product](db).Set(Assign("Attrs", Attrs{Color: "red"})).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `product` SET `attrs`=? WHERE `id` = ?;",
+				Args: []any{`{"color":"red"}`, 1},
+			},
+		},
+		{
+			// NULL 不需要编码
+			name: "assign nil",
+			q:    NewUpdater[Product](db).Set(Assign("Attrs", nil)).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `product` SET `attrs`=? WHERE `id` = ?;",
+				Args: []any{nil, 1},
+			},
+		},
+		{
+			// JsonColumn 自己负责编码
+			name: "assign json column",
+			q: NewUpdater[Product](db).
+				Set(Assign("Tags", JsonColumn[[]string]{Val: []string{"a"}, Valid: true})).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `product` SET `tags`=? WHERE `id` = ?;",
+				Args: []any{JsonColumn[[]string]{Val: []string{"a"}, Valid: true}, 1},
+			},
+		},
+		{
+			name: "where",
+			q: NewUpdater[Product](db).Set(Assign("Id", 2)).
+				Where(C("Attrs").EQ(Attrs{Color: "red"})),
+			wantQuery: &Query{
+				SQL:  "UPDATE `product` SET `id`=? WHERE `attrs` = ?;",
+				Args: []any{2, `{"color":"red"}`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
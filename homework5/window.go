@@ -0,0 +1,76 @@
+package orm
+
+// Window 代表窗口的定义，也就是 OVER 后面括号里面的部分
+// 例如 PartitionBy(C("Dept")).OrderBy(Desc(C("Salary")))
+type Window struct {
+	partitionBy []Column
+	orderBy     []OrderBy
+}
+
+// PartitionBy 按照 cols 分区，不传入任何列意味着整个结果集是一个分区
+func PartitionBy(cols ...Column) Window {
+	return Window{
+		partitionBy: cols,
+	}
+}
+
+func (w Window) OrderBy(obs ...OrderBy) Window {
+	w.orderBy = obs
+	return w
+}
+
+// WindowExpr 代表窗口函数，例如 RowNumber().Over(PartitionBy(C("Dept")))
+// 聚合函数也可以作为窗口函数使用，例如 Sum("Salary").Over(...)
+type WindowExpr struct {
+	fn     Expression
+	window Window
+	alias  string
+}
+
+// RowNumber 分区里面的行号，从 1 开始
+func RowNumber() FuncExpr {
+	return Fn("ROW_NUMBER")
+}
+
+// Rank 排名，相同的值排名相同，后面的排名会跳过
+func Rank() FuncExpr {
+	return Fn("RANK")
+}
+
+// DenseRank 排名，相同的值排名相同，后面的排名不会跳过
+func DenseRank() FuncExpr {
+	return Fn("DENSE_RANK")
+}
+
+func (f FuncExpr) Over(w Window) WindowExpr {
+	return WindowExpr{
+		fn:     f,
+		window: w,
+	}
+}
+
+func (a Aggregate) Over(w Window) WindowExpr {
+	return WindowExpr{
+		fn:     a,
+		window: w,
+	}
+}
+
+func (w WindowExpr) As(alias string) WindowExpr {
+	w.alias = alias
+	return w
+}
+
+func (w WindowExpr) selectedAlias() string {
+	return w.alias
+}
+
+func (w WindowExpr) fieldName() string {
+	return ""
+}
+
+func (w WindowExpr) target() TableReference {
+	return nil
+}
+
+func (w WindowExpr) expr() {}
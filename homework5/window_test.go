@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"exercise/geektime/homework5/version1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWindow_Build(t *testing.T) {
+	mockdb, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockdb)
+	require.NoError(t, err)
+
+	type Salary struct {
+		Id     int64
+		Dept   string
+		Amount int64
+	}
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "row number",
+			q: NewSelector[Salary](db).Select(C("Id"),
+				RowNumber().Over(PartitionBy(C("Dept")).OrderBy(Desc(C("Amount")))).As("rn")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,ROW_NUMBER() OVER (PARTITION BY `dept` ORDER BY `amount` DESC) AS `rn` FROM `salary`;",
+			},
+		},
+		{
+			name: "rank without partition",
+			q: NewSelector[Salary](db).Select(C("Id"),
+				Rank().Over(PartitionBy().OrderBy(Desc(C("Amount")), Asc(C("Id")))).As("r"),
+				DenseRank().Over(PartitionBy(C("Dept"), C("Id"))).As("dr")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,RANK() OVER (ORDER BY `amount` DESC,`id` ASC) AS `r`,DENSE_RANK() OVER (PARTITION BY `dept`,`id`) AS `dr` FROM `salary`;",
+			},
+		},
+		{
+			// 聚合函数作为窗口函数，例如累计值
+			name: "running total",
+			q: NewSelector[Salary](db).Select(C("Id"),
+				Sum("Amount").Over(PartitionBy(C("Dept")).OrderBy(Asc(C("Id")))).As("total"),
+				Avg("Amount").Over(PartitionBy())),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,SUM(`amount`) OVER (PARTITION BY `dept` ORDER BY `id` ASC) AS `total`,AVG(`amount`) OVER () FROM `salary`;",
+			},
+		},
+		{
+			// 作为子查询的列，外面通过别名引用
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[Salary](db).Select(C("Id"), C("Dept"),
+					RowNumber().Over(PartitionBy(C("Dept")).OrderBy(Desc(C("Amount")))).As("rn")).
+					AsSubquery("ranked")
+				return NewSelector[Salary](db).Select(sub.C("Id"), sub.C("Dept"), sub.C("rn")).
+					From(sub).Where(sub.C("rn").LTEQ(3))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT `ranked`.`id`,`ranked`.`dept`,`ranked`.`rn` FROM (SELECT `id`,`dept`,ROW_NUMBER() OVER (PARTITION BY `dept` ORDER BY `amount` DESC) AS `rn` FROM `salary`) AS `ranked` WHERE `ranked`.`rn` <= ?;",
+				Args: []any{3},
+			},
+		},
+		{
+			name: "invalid column",
+			q: NewSelector[Salary](db).Select(
+				RowNumber().Over(PartitionBy(C("Invalid")))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "invalid subquery alias",
+			q: func() QueryBuilder {
+				sub := NewSelector[Salary](db).Select(C("Id"),
+					RowNumber().Over(PartitionBy(C("Dept"))).As("rn")).AsSubquery("ranked")
+				return NewSelector[Salary](db).Select(sub.C("rank")).From(sub)
+			}(),
+			wantErr: errs.NewErrUnknownField("rank"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}